@@ -0,0 +1,159 @@
+package nats_client
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// applyOptions 将选项应用到默认 nats.Options 上以便检查
+func applyOptions(t *testing.T, cfg *Config) nats.Options {
+	t.Helper()
+	opts, err := cfg.NATSOptions()
+	if err != nil {
+		t.Fatalf("生成选项失败: %v", err)
+	}
+	o := nats.GetDefaultOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			t.Fatalf("应用选项失败: %v", err)
+		}
+	}
+	return o
+}
+
+// TestConfigDefaults 测试默认配置与 NewNATSConnect 保持一致
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("创建配置失败: %v", err)
+	}
+	o := applyOptions(t, cfg)
+	if o.Name != DefaultName {
+		t.Errorf("连接名称不匹配: got %q, want %q", o.Name, DefaultName)
+	}
+	if o.CustomDialer == nil {
+		t.Error("默认应使用代理拨号器")
+	}
+	if o.ConnectedCB == nil {
+		t.Error("默认应设置连接回调")
+	}
+}
+
+// TestConfigOptions 测试函数式选项
+func TestConfigOptions(t *testing.T) {
+	cfg, err := NewConfig(
+		WithName("svc"),
+		WithURL("nats://a:4222,nats://b:4222"),
+		WithUserInfo("alice", "secret"),
+		WithReconnect(-1, 3*time.Second),
+		WithReconnectJitter(time.Second),
+		WithTimeout(5*time.Second),
+		WithDialer(nil),
+		WithNATSOptions(nats.NoEcho()),
+	)
+	if err != nil {
+		t.Fatalf("创建配置失败: %v", err)
+	}
+	o := applyOptions(t, cfg)
+	if o.Name != "svc" || o.User != "alice" || o.Password != "secret" {
+		t.Errorf("认证信息不匹配: %+v", o)
+	}
+	if o.MaxReconnect != -1 || o.ReconnectWait != 3*time.Second || o.ReconnectJitter != time.Second {
+		t.Errorf("重连参数不匹配: %d %v %v", o.MaxReconnect, o.ReconnectWait, o.ReconnectJitter)
+	}
+	if o.Timeout != 5*time.Second {
+		t.Errorf("超时不匹配: %v", o.Timeout)
+	}
+	if o.CustomDialer != nil {
+		t.Error("WithDialer(nil) 应关闭代理拨号器")
+	}
+	if !o.NoEcho {
+		t.Error("附加选项未生效")
+	}
+}
+
+// TestConfigValidate 测试互斥的认证方式
+func TestConfigValidate(t *testing.T) {
+	cases := map[string][]Option{
+		"user+token":  {WithUserInfo("a", "b"), WithToken("t")},
+		"creds+nkey":  {WithCredsFile("a.creds"), WithNKeyFile("a.nk")},
+		"cert no key": {WithClientCert("c.pem", "")},
+	}
+	for name, opts := range cases {
+		cfg, err := NewConfig(opts...)
+		if err != nil {
+			t.Fatalf("%s: 创建配置失败: %v", name, err)
+		}
+		if _, err := cfg.NATSOptions(); err == nil {
+			t.Errorf("%s: 期望校验失败", name)
+		}
+	}
+}
+
+// TestConfigFromEnv 测试从环境变量读取配置
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("NATS_URL", "nats://env:4222")
+	t.Setenv("NATS_TOKEN", "s3cr3t")
+	t.Setenv("NATS_TIMEOUT", "7s")
+	t.Setenv("NATS_MAX_RECONNECTS", "12")
+
+	cfg, err := NewConfig(WithEnv())
+	if err != nil {
+		t.Fatalf("读取环境变量失败: %v", err)
+	}
+	if cfg.URL != "nats://env:4222" || cfg.Token != "s3cr3t" {
+		t.Errorf("环境变量未生效: %+v", cfg)
+	}
+	if cfg.Timeout != 7*time.Second || cfg.MaxReconnects != 12 {
+		t.Errorf("数值环境变量未生效: %v %d", cfg.Timeout, cfg.MaxReconnects)
+	}
+
+	t.Setenv("NATS_TIMEOUT", "soon")
+	if _, err := NewConfig(WithEnv()); err == nil {
+		t.Error("期望无效的 NATS_TIMEOUT 报错")
+	}
+}
+
+// TestConfigFile 测试从 JSON 文件读取配置，并可被后续选项覆盖
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nats.json")
+	data := `{"name":"from-file","url":"nats://file:4222","user":"bob","password":"pw","reconnect_wait":"250ms","timeout":1000000000,"max_reconnects":3}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := NewConfig(WithConfigFile(path), WithName("override"))
+	if err != nil {
+		t.Fatalf("读取配置文件失败: %v", err)
+	}
+	if cfg.Name != "override" || cfg.URL != "nats://file:4222" || cfg.User != "bob" {
+		t.Errorf("配置文件未生效: %+v", cfg)
+	}
+	if cfg.ReconnectWait != 250*time.Millisecond || cfg.Timeout != time.Second || cfg.MaxReconnects != 3 {
+		t.Errorf("时间字段解析错误: %v %v %d", cfg.ReconnectWait, cfg.Timeout, cfg.MaxReconnects)
+	}
+	if cfg.Dialer == nil {
+		t.Error("配置文件不应清除默认拨号器")
+	}
+}
+
+// TestConfigFlags 测试命令行参数
+func TestConfigFlags(t *testing.T) {
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	err := fs.Parse([]string{"-nats-url", "nats://flag:4222", "-nats-creds", "user.creds", "-nats-reconnect-wait", "1s"})
+	if err != nil {
+		t.Fatalf("解析参数失败: %v", err)
+	}
+	if cfg.URL != "nats://flag:4222" || cfg.CredsFile != "user.creds" || cfg.ReconnectWait != time.Second {
+		t.Errorf("命令行参数未生效: %+v", cfg)
+	}
+	if cfg.Name != DefaultName {
+		t.Errorf("未指定的参数应保持默认值: %q", cfg.Name)
+	}
+}
@@ -0,0 +1,170 @@
+package nats_client
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// Creds 用户 JWT 与签名 nonce 使用的 NKey 种子
+type Creds struct {
+	JWT  string
+	Seed []byte
+}
+
+// CredsProvider 在每次 (重) 连接时提供当前的凭证，
+// 可以从文件、密钥管理服务或签发服务获取
+type CredsProvider interface {
+	Creds() (*Creds, error)
+}
+
+// CredsFunc 将函数适配为 CredsProvider
+type CredsFunc func() (*Creds, error)
+
+func (f CredsFunc) Creds() (*Creds, error) {
+	return f()
+}
+
+// CredsFile 从 nats 凭证文件 (.creds) 读取 JWT 与种子。
+// 每次连接获取凭证时按内容判断文件是否轮换，新文件无法解析时继续使用上一次的凭证
+type CredsFile struct {
+	Path string
+	// Logger 记录重新加载失败与轮换，为 nil 时使用 slog.Default()
+	Logger *slog.Logger
+	// ForceReconnect 为 true 时 Watch 发现轮换后强制重连，否则只预先加载，下一次重连时生效
+	ForceReconnect bool
+
+	mu    sync.Mutex
+	stamp fileStamp
+	creds *Creds
+}
+
+// NewCredsFile 加载凭证文件
+func NewCredsFile(path string) (*CredsFile, error) {
+	f := &CredsFile{Path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload 文件变化时重新加载，返回凭证是否被替换
+func (f *CredsFile) Reload() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st, err := statFiles(f.Path)
+	if err != nil {
+		return false, fmt.Errorf("nats: creds %s: %w", f.Path, err)
+	}
+	if st == f.stamp {
+		return false, nil
+	}
+	creds, err := loadCreds(f.Path)
+	if err != nil {
+		return false, err
+	}
+	f.creds, f.stamp = creds, st
+	return true, nil
+}
+
+// loadCreds 解析凭证文件中的 JWT 与种子
+func loadCreds(path string) (*Creds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("nats: read creds: %w", err)
+	}
+	jwt, err := nkeys.ParseDecoratedJWT(data)
+	if err != nil {
+		return nil, fmt.Errorf("nats: parse creds %s: %w", path, err)
+	}
+	kp, err := nkeys.ParseDecoratedNKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("nats: parse creds %s: %w", path, err)
+	}
+	defer kp.Wipe()
+	seed, err := kp.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("nats: parse creds %s: %w", path, err)
+	}
+	// Wipe 会覆盖 kp 内部的种子，这里保留副本
+	return &Creds{JWT: jwt, Seed: append([]byte(nil), seed...)}, nil
+}
+
+// Creds 返回当前的凭证，供每次连接时调用
+func (f *CredsFile) Creds() (*Creds, error) {
+	if _, err := f.Reload(); err != nil {
+		logger(f.Logger).Warn("nats creds reload failed, using previous", LogKeyPath, f.Path, errAttr(err))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.creds == nil {
+		return nil, fmt.Errorf("nats: creds %s not loaded", f.Path)
+	}
+	return f.creds, nil
+}
+
+// Watch 定期检查凭证文件，预先加载并校验轮换后的内容，连接不会断开。
+// 旧 JWT 过期等原因触发的下一次重连使用新的凭证，新文件无法解析时继续使用上一次的凭证。
+// ForceReconnect 为 true 时发现轮换后刷新已经缓冲的发布并强制重连，使新的凭证立即生效，
+// 代价是断开当前连接：正在传输中的消息与尚未收到的请求响应可能丢失。连接关闭后自动退出
+func (f *CredsFile) Watch(nc *nats.Conn, interval time.Duration) {
+	watchFiles(nc, interval, f.Logger, "creds", f.Path, f.ForceReconnect, f.Reload)
+}
+
+// credsOption 返回在每次连接时向 p 获取凭证的 nats.Option。
+// 同一次连接中签名使用与 JWT 同一份凭证
+func credsOption(p CredsProvider) nats.Option {
+	var mu sync.Mutex
+	var current *Creds
+	return nats.UserJWT(
+		func() (string, error) {
+			creds, err := p.Creds()
+			if err != nil {
+				return "", err
+			}
+			if creds == nil || creds.JWT == "" {
+				return "", errors.New("nats: creds provider returned no jwt")
+			}
+			mu.Lock()
+			current = creds
+			mu.Unlock()
+			return creds.JWT, nil
+		},
+		func(nonce []byte) ([]byte, error) {
+			mu.Lock()
+			creds := current
+			mu.Unlock()
+			if creds == nil {
+				return nil, errors.New("nats: no creds to sign nonce")
+			}
+			kp, err := nkeys.FromSeed(creds.Seed)
+			if err != nil {
+				return nil, fmt.Errorf("nats: invalid creds seed: %w", err)
+			}
+			defer kp.Wipe()
+			return kp.Sign(nonce)
+		},
+	)
+}
+
+// WithCredsProvider 使用 p 在每次连接时提供的凭证认证
+func WithCredsProvider(p CredsProvider) Option {
+	return func(c *Config) error {
+		c.CredsProvider = p
+		return nil
+	}
+}
+
+// WithCredsWatch 定期检查并预先加载 CredsFile，配合 WithReconnectOnRotate 在轮换后强制重连
+func WithCredsWatch(interval time.Duration) Option {
+	return func(c *Config) error {
+		c.CredsWatchInterval = interval
+		return nil
+	}
+}
@@ -0,0 +1,255 @@
+package nats_client
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// testIssuer 运行在 operator 模式下的服务器与签发用户 JWT 的账户
+type testIssuer struct {
+	server  *server.Server
+	account nkeys.KeyPair
+}
+
+func runOperatorServer(t *testing.T) *testIssuer {
+	t.Helper()
+	okp, _ := nkeys.CreateOperator()
+	opub, _ := okp.PublicKey()
+	oc := jwt.NewOperatorClaims(opub)
+	ojwt, err := oc.Encode(okp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oclaims, _ := jwt.DecodeOperatorClaims(ojwt)
+
+	akp, _ := nkeys.CreateAccount()
+	apub, _ := akp.PublicKey()
+	ajwt, err := jwt.NewAccountClaims(apub).Encode(okp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := &server.MemAccResolver{}
+	resolver.Store(apub, ajwt)
+
+	s := natstest.RunServer(t, natstest.WithoutJetStream(), func(o *server.Options) {
+		o.TrustedOperators = []*jwt.OperatorClaims{oclaims}
+		o.AccountResolver = resolver
+	})
+	return &testIssuer{server: s, account: akp}
+}
+
+// issue 签发一个新的用户，ttl 为 0 表示不过期，返回凭证与用户公钥
+func (ti *testIssuer) issue(t *testing.T, ttl time.Duration) (*Creds, string) {
+	t.Helper()
+	ukp, _ := nkeys.CreateUser()
+	upub, _ := ukp.PublicKey()
+	seed, _ := ukp.Seed()
+	uc := jwt.NewUserClaims(upub)
+	if ttl > 0 {
+		uc.Expires = time.Now().Add(ttl).Unix()
+	}
+	ujwt, err := uc.Encode(ti.account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Creds{JWT: ujwt, Seed: seed}, upub
+}
+
+// writeCreds 以 nsc 生成的 .creds 格式写入文件，先写临时文件再改名
+func writeCreds(t *testing.T, path string, creds *Creds) {
+	t.Helper()
+	data, err := jwt.FormatUserConfig(creds.JWT, creds.Seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := path + ".tmp"
+	writeFile(t, tmp, data)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// authorizedUser 返回服务器上名为 name 的连接所使用的用户公钥
+func authorizedUser(t *testing.T, s *server.Server, name string) string {
+	t.Helper()
+	cz, err := s.Connz(&server.ConnzOptions{Username: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cz.Conns {
+		if c.Name == name {
+			return c.AuthorizedUser
+		}
+	}
+	return ""
+}
+
+// TestCredsFileRotation 测试开启 ReconnectOnRotate 时凭证文件轮换后使用新用户重连，订阅不受影响
+func TestCredsFileRotation(t *testing.T) {
+	ti := runOperatorServer(t)
+	path := filepath.Join(t.TempDir(), "user.creds")
+	creds1, user1 := ti.issue(t, 0)
+	writeCreds(t, path, creds1)
+
+	events := make(chan Event, 64)
+	nc, err := Connect(
+		WithURL(ti.server.ClientURL()),
+		WithName("rotating"),
+		WithDialer(nil),
+		WithCredsFile(path),
+		WithCredsWatch(20*time.Millisecond),
+		WithReconnectOnRotate(),
+		WithReconnect(-1, 20*time.Millisecond),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("使用凭证文件连接失败: %v", err)
+	}
+	defer nc.Close()
+	if got := authorizedUser(t, ti.server, "rotating"); got != user1 {
+		t.Fatalf("用户不匹配: got %s, want %s", got, user1)
+	}
+	sub, err := nc.SubscribeSync("creds.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按内容判断轮换，修改时间不变也能发现
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds2, user2 := ti.issue(t, 0)
+	writeCreds(t, path, creds2)
+	if err := os.Chtimes(path, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, EventReconnected)
+	if got := authorizedUser(t, ti.server, "rotating"); got != user2 {
+		t.Errorf("轮换后用户不匹配: got %s, want %s", got, user2)
+	}
+
+	if err := nc.Publish("creds.test", []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.NextMsg(2 * time.Second); err != nil {
+		t.Errorf("轮换后订阅未恢复: %v", err)
+	}
+
+	// 新文件无法解析时不重连，继续使用上一次的凭证
+	writeFile(t, path, []byte("garbage"))
+	cf := &CredsFile{Path: path}
+	if _, err := cf.Reload(); err == nil {
+		t.Error("无效的凭证文件期望报错")
+	}
+	for len(events) > 0 {
+		<-events
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !nc.IsConnected() {
+		t.Fatalf("无效的凭证文件不应断开连接: %v", nc.Status())
+	}
+	for len(events) > 0 {
+		if e := <-events; e.Type == EventDisconnected || e.Type == EventReconnected || e.Type == EventAsyncError {
+			t.Errorf("无效的凭证文件不应产生事件: %v", e.Type)
+		}
+	}
+	if got := authorizedUser(t, ti.server, "rotating"); got != user2 {
+		t.Errorf("无效的凭证文件后用户不匹配: got %s, want %s", got, user2)
+	}
+}
+
+// TestCredsFileWatchReload 测试默认只预先加载轮换的凭证文件，连接不断开，下一次重连时使用新用户
+func TestCredsFileWatchReload(t *testing.T) {
+	ti := runOperatorServer(t)
+	path := filepath.Join(t.TempDir(), "user.creds")
+	creds1, user1 := ti.issue(t, 0)
+	writeCreds(t, path, creds1)
+
+	events := make(chan Event, 64)
+	nc, err := Connect(
+		WithURL(ti.server.ClientURL()),
+		WithName("reloading"),
+		WithDialer(nil),
+		WithCredsFile(path),
+		WithCredsWatch(20*time.Millisecond),
+		WithReconnect(-1, 20*time.Millisecond),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("使用凭证文件连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	creds2, user2 := ti.issue(t, 0)
+	writeCreds(t, path, creds2)
+	time.Sleep(100 * time.Millisecond)
+	for len(events) > 0 {
+		if e := <-events; e.Type == EventDisconnected || e.Type == EventReconnected {
+			t.Fatalf("默认不应在轮换后重连: %v", e.Type)
+		}
+	}
+	if got := authorizedUser(t, ti.server, "reloading"); got != user1 {
+		t.Errorf("重连前用户不匹配: got %s, want %s", got, user1)
+	}
+
+	if err := nc.ForceReconnect(); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, EventReconnected)
+	if got := authorizedUser(t, ti.server, "reloading"); got != user2 {
+		t.Errorf("重连后用户不匹配: got %s, want %s", got, user2)
+	}
+}
+
+// TestCredsProviderExpiry 测试 JWT 过期被服务器断开后，重连时向提供者获取新的 JWT
+func TestCredsProviderExpiry(t *testing.T) {
+	ti := runOperatorServer(t)
+	var issued atomic.Int32
+	provider := CredsFunc(func() (*Creds, error) {
+		issued.Add(1)
+		creds, _ := ti.issue(t, time.Second)
+		return creds, nil
+	})
+
+	events := make(chan Event, 64)
+	nc, err := Connect(
+		WithURL(ti.server.ClientURL()),
+		WithDialer(nil),
+		WithCredsProvider(provider),
+		WithReconnect(-1, 20*time.Millisecond),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("使用凭证提供者连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	waitEvent(t, events, EventDisconnected)
+	waitEvent(t, events, EventReconnected)
+	if n := issued.Load(); n < 2 {
+		t.Errorf("重连时应重新获取凭证: %d", n)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Errorf("重连后连接不可用: %v", err)
+	}
+}
+
+// TestCredsExclusive 测试凭证文件与提供者互斥
+func TestCredsExclusive(t *testing.T) {
+	cfg, err := NewConfig(WithCredsFile("a.creds"), WithCredsProvider(CredsFunc(func() (*Creds, error) { return nil, nil })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("同时设置凭证文件与提供者时期望报错")
+	}
+}
@@ -0,0 +1,373 @@
+package nats_client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyError 经由代理建立连接失败，与服务器本身的错误区分开
+type ProxyError struct {
+	Proxy      string // 代理地址，已隐藏密码
+	Target     string // 目标服务器 host:port
+	StatusCode int    // HTTP CONNECT 的响应码，其他情况为 0
+	Err        error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("nats: proxy %s: connect to %s: %s", e.Proxy, e.Target, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// proxyErrDialer 记录拨号返回的最后一个 *ProxyError。
+// nats.go 在首次连接时会丢弃包含 "connection refused" 的拨号错误并返回 ErrNoServers，
+// Config.Connect 据此把代理错误附加到 ErrNoServers 上，供 errors.As 取出
+type proxyErrDialer struct {
+	nats.CustomDialer
+
+	mu   sync.Mutex
+	last *ProxyError
+}
+
+func (d *proxyErrDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := d.CustomDialer.Dial(network, address)
+	var pe *ProxyError
+	if errors.As(err, &pe) {
+		d.mu.Lock()
+		d.last = pe
+		d.mu.Unlock()
+	}
+	return conn, err
+}
+
+// SkipTLSHandshake 转发到被包装的拨号器，例如 WebSocket 拨号器自行完成 TLS
+func (d *proxyErrDialer) SkipTLSHandshake() bool {
+	sd, ok := d.CustomDialer.(interface{ SkipTLSHandshake() bool })
+	return ok && sd.SkipTLSHandshake()
+}
+
+// option 包装最终生效的拨号器，必须放在选项列表的最后
+func (d *proxyErrDialer) option() nats.Option {
+	return func(o *nats.Options) error {
+		if o.CustomDialer != nil {
+			d.CustomDialer, o.CustomDialer = o.CustomDialer, d
+		}
+		return nil
+	}
+}
+
+// wrap 连接失败且记录到代理错误时，把代理错误附加到 err 上
+func (d *proxyErrDialer) wrap(err error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last == nil || !errors.Is(err, nats.ErrNoServers) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, d.last)
+}
+
+// ProxyDialer 支持 SOCKS5 与 HTTP(S) CONNECT 代理的拨号器，实现 nats.CustomDialer。
+// 匹配 NoProxy 的地址以及回环地址直接连接。
+type ProxyDialer struct {
+	// URL 代理地址，支持 socks5、socks5h、http、https，nil 表示直连。
+	// socks5 与 socks5h 相同，都把域名交给代理解析，不在本地做 DNS 查询
+	URL *url.URL
+	// NoProxy 逗号分隔的直连列表，格式与 NO_PROXY 相同:
+	// 域名 (example.com 同时匹配子域名)、IP、CIDR、可选的 :port，* 表示全部直连
+	NoProxy string
+	// Timeout 建立连接 (含代理握手) 的超时，0 表示不限制
+	Timeout time.Duration
+	// TLSConfig 连接 https 代理时使用的 TLS 配置
+	TLSConfig *tls.Config
+
+	err error // 环境变量中的代理地址无效
+}
+
+// NewProxyDialer 使用代理地址与直连列表创建拨号器，proxyURL 为空表示直连
+func NewProxyDialer(proxyURL, noProxy string) (*ProxyDialer, error) {
+	d := &ProxyDialer{NoProxy: noProxy, Timeout: nats.DefaultTimeout}
+	if proxyURL == "" {
+		return d, nil
+	}
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	d.URL = u
+	return d, nil
+}
+
+// ProxyFromEnvironment 从 ALL_PROXY 读取代理地址，并使用 NO_PROXY 作为直连列表，
+// 未设置代理时直接连接。HTTPS_PROXY 与 HTTP_PROXY 通常只面向 HTTP 流量，这里不读取，
+// 需要时使用 HTTPProxyFromEnvironment
+func ProxyFromEnvironment() *ProxyDialer {
+	return proxyFromEnv("ALL_PROXY", "all_proxy")
+}
+
+// HTTPProxyFromEnvironment 与 ProxyFromEnvironment 相同，但在 ALL_PROXY 之后
+// 依次读取 HTTPS_PROXY、HTTP_PROXY，使 NATS 连接也经过为 HTTP 设置的代理
+func HTTPProxyFromEnvironment() *ProxyDialer {
+	return proxyFromEnv("ALL_PROXY", "all_proxy", "HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy")
+}
+
+// proxyFromEnv 按顺序读取环境变量 names 中第一个非空的代理地址
+func proxyFromEnv(names ...string) *ProxyDialer {
+	d := &ProxyDialer{NoProxy: getEnvAny("NO_PROXY", "no_proxy"), Timeout: nats.DefaultTimeout}
+	if v := getEnvAny(names...); v != "" {
+		// 地址无效时推迟到拨号时报告，避免静默直连
+		d.URL, d.err = parseProxyURL(v)
+	}
+	return d
+}
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseProxyURL 解析代理地址，未写协议时按 http 处理
+func parseProxyURL(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("nats: invalid proxy %q: %w", s, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return nil, fmt.Errorf("nats: unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("nats: invalid proxy %q: missing host", s)
+	}
+	return u, nil
+}
+
+// Dial 实现 nats.CustomDialer
+func (d *ProxyDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext 建立到 address 的连接，需要时经过代理
+func (d *ProxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	if d.err != nil {
+		return nil, &ProxyError{Target: address, Err: d.err}
+	}
+	if d.URL == nil || d.bypass(address) {
+		var nd net.Dialer
+		return nd.DialContext(ctx, network, address)
+	}
+
+	var conn net.Conn
+	var err error
+	switch d.URL.Scheme {
+	case "socks5", "socks5h":
+		conn, err = d.dialSOCKS5(ctx, network, address)
+	default:
+		conn, err = d.dialConnect(ctx, address)
+	}
+	if err != nil {
+		if pe, ok := err.(*ProxyError); ok {
+			return nil, pe
+		}
+		return nil, &ProxyError{Proxy: d.URL.Redacted(), Target: address, Err: err}
+	}
+	return conn, nil
+}
+
+// proxyAddr 返回代理的 host:port，未写端口时使用协议的默认端口
+func (d *ProxyDialer) proxyAddr() string {
+	if d.URL.Port() != "" {
+		return d.URL.Host
+	}
+	port := "80"
+	switch d.URL.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(d.URL.Hostname(), port)
+}
+
+func (d *ProxyDialer) dialSOCKS5(ctx context.Context, network, address string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if u := d.URL.User; u != nil {
+		password, _ := u.Password()
+		auth = &proxy.Auth{User: u.Username(), Password: password}
+	}
+	sd, err := proxy.SOCKS5("tcp", d.proxyAddr(), auth, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+	return sd.(proxy.ContextDialer).DialContext(ctx, network, address)
+}
+
+// dialConnect 通过 HTTP CONNECT 建立隧道
+func (d *ProxyDialer) dialConnect(ctx context.Context, address string) (net.Conn, error) {
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, "tcp", d.proxyAddr())
+	if err != nil {
+		return nil, err
+	}
+	// 握手期间 ctx 被取消或超时时中断读写
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if d.URL.Scheme == "https" {
+		cfg := d.TLSConfig.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = d.URL.Hostname()
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if u := d.URL.User; u != nil {
+		password, _ := u.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &ProxyError{
+			Proxy:      d.URL.Redacted(),
+			Target:     address,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("CONNECT: %s", resp.Status),
+		}
+	}
+	if !stop() {
+		// ctx 已经结束，截止时间已被设置
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	// 服务器在隧道建立后立即发送 INFO，可能已被读入缓冲区
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先返回缓冲区中已读取的数据
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// bypass 判断 address 是否应当直连
+func (d *ProxyDialer) bypass(address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+	return matchNoProxy(d.NoProxy, host, port)
+}
+
+// matchNoProxy 判断 host:port 是否匹配 NO_PROXY 格式的列表
+func matchNoProxy(noProxy, host, port string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		if eip := net.ParseIP(strings.Trim(entry, "[]")); eip != nil {
+			if ip != nil && eip.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithProxy 经由代理连接服务器，noProxy 中的地址直接连接
+func WithProxy(proxyURL, noProxy string) Option {
+	return func(c *Config) error {
+		if _, err := parseProxyURL(proxyURL); err != nil {
+			return err
+		}
+		c.Proxy, c.NoProxy = proxyURL, noProxy
+		return nil
+	}
+}
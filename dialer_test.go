@@ -0,0 +1,383 @@
+package nats_client
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// testConnectProxy 最小的 HTTP CONNECT 代理，所有隧道都转发到 backend，
+// 记录客户端请求的目标地址
+type testConnectProxy struct {
+	ln      net.Listener
+	backend string
+	auth    string // 期望的 Proxy-Authorization，空表示不认证
+
+	mu      sync.Mutex
+	targets []string
+	fail    bool
+	conns   []net.Conn
+}
+
+func runConnectProxy(t *testing.T, backend, user, password string) *testConnectProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testConnectProxy{ln: ln, backend: backend}
+	if user != "" {
+		p.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		p.dropAll()
+	})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(c)
+		}
+	}()
+	return p
+}
+
+func (p *testConnectProxy) serve(c net.Conn) {
+	br := bufio.NewReader(c)
+	req, err := http.ReadRequest(br)
+	if err != nil || req.Method != http.MethodConnect {
+		c.Close()
+		return
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, req.Host)
+	fail := p.fail
+	p.mu.Unlock()
+
+	reply := func(status int) {
+		io.WriteString(c, "HTTP/1.1 "+strconv.Itoa(status)+" "+http.StatusText(status)+"\r\n\r\n")
+	}
+	switch {
+	case fail:
+		reply(http.StatusBadGateway)
+		c.Close()
+		return
+	case p.auth != "" && req.Header.Get("Proxy-Authorization") != p.auth:
+		reply(http.StatusProxyAuthRequired)
+		c.Close()
+		return
+	}
+	backend, err := net.Dial("tcp", p.backend)
+	if err != nil {
+		reply(http.StatusBadGateway)
+		c.Close()
+		return
+	}
+	reply(http.StatusOK)
+	p.mu.Lock()
+	p.conns = append(p.conns, c, backend)
+	p.mu.Unlock()
+	go func() { io.Copy(backend, br); backend.Close() }()
+	io.Copy(c, backend)
+	c.Close()
+}
+
+// dropAll 断开所有隧道
+func (p *testConnectProxy) dropAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
+
+func (p *testConnectProxy) setFail(fail bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail = fail
+}
+
+func (p *testConnectProxy) lastTarget() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.targets) == 0 {
+		return ""
+	}
+	return p.targets[len(p.targets)-1]
+}
+
+// runSOCKS5Proxy 最小的 SOCKS5 代理，只支持用户名密码认证与 CONNECT 命令，
+// 所有连接都转发到 backend，返回代理地址与目标地址通道
+func runSOCKS5Proxy(t *testing.T, backend, user, password string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	targets := make(chan string, 16)
+	serve := func(c net.Conn) {
+		defer c.Close()
+		br := bufio.NewReader(c)
+		buf := make([]byte, 256)
+		// 协商认证方式: VER NMETHODS METHODS
+		if _, err := io.ReadFull(br, buf[:2]); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(br, buf[:buf[1]]); err != nil {
+			return
+		}
+		c.Write([]byte{5, 2})
+		// 用户名密码认证: VER ULEN UNAME PLEN PASSWD
+		if _, err := io.ReadFull(br, buf[:2]); err != nil {
+			return
+		}
+		u := make([]byte, buf[1])
+		io.ReadFull(br, u)
+		io.ReadFull(br, buf[:1])
+		pw := make([]byte, buf[0])
+		io.ReadFull(br, pw)
+		if string(u) != user || string(pw) != password {
+			c.Write([]byte{1, 1})
+			return
+		}
+		c.Write([]byte{1, 0})
+		// 请求: VER CMD RSV ATYP DST.ADDR DST.PORT
+		if _, err := io.ReadFull(br, buf[:4]); err != nil {
+			return
+		}
+		var host string
+		switch buf[3] {
+		case 1:
+			io.ReadFull(br, buf[:4])
+			host = net.IP(buf[:4]).String()
+		case 3:
+			io.ReadFull(br, buf[:1])
+			name := make([]byte, buf[0])
+			io.ReadFull(br, name)
+			host = string(name)
+		default:
+			return
+		}
+		io.ReadFull(br, buf[:2])
+		targets <- net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+		b, err := net.Dial("tcp", backend)
+		if err != nil {
+			c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer b.Close()
+		c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(b, br)
+		io.Copy(c, b)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(c)
+		}
+	}()
+	return ln.Addr().String(), targets
+}
+
+// TestHTTPConnectProxy 测试经由需要认证的 HTTP CONNECT 代理连接
+func TestHTTPConnectProxy(t *testing.T) {
+	s := natstest.RunServer(t)
+	p := runConnectProxy(t, s.Addr().String(), "alice", "s3cret")
+
+	// 目标主机名只有代理能够解析
+	nc, err := Connect(
+		WithURL("nats://nats.internal:4222"),
+		WithProxy("http://alice:s3cret@"+p.ln.Addr().String(), ""),
+	)
+	if err != nil {
+		t.Fatalf("经由代理连接失败: %v", err)
+	}
+	defer nc.Close()
+	if got := p.lastTarget(); got != "nats.internal:4222" {
+		t.Errorf("代理收到的目标不匹配: %q", got)
+	}
+	if _, err := nc.RTT(); err != nil {
+		t.Errorf("经由代理通信失败: %v", err)
+	}
+}
+
+// TestProxyErrors 测试代理自身的故障返回 *ProxyError
+func TestProxyErrors(t *testing.T) {
+	s := natstest.RunServer(t)
+	p := runConnectProxy(t, s.Addr().String(), "alice", "s3cret")
+
+	_, err := Connect(
+		WithURL("nats://nats.internal:4222"),
+		WithProxy("http://alice:wrong@"+p.ln.Addr().String(), ""),
+		WithReconnect(0, 0),
+	)
+	var pe *ProxyError
+	if !errors.As(err, &pe) {
+		t.Fatalf("期望 *ProxyError，实际: %v", err)
+	}
+	if pe.StatusCode != http.StatusProxyAuthRequired || pe.Target != "nats.internal:4222" {
+		t.Errorf("代理错误不匹配: %+v", pe)
+	}
+	if pe.Proxy != "http://alice:xxxxx@"+p.ln.Addr().String() {
+		t.Errorf("代理地址应隐藏密码: %q", pe.Proxy)
+	}
+
+	// 代理不可达时同样返回 *ProxyError，而不是 nats.ErrNoServers
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := ln.Addr().String()
+	ln.Close()
+	_, err = Connect(WithURL("nats://nats.internal:4222"), WithProxy("http://"+dead, ""))
+	if !errors.As(err, &pe) {
+		t.Fatalf("代理不可达时期望 *ProxyError，实际: %v", err)
+	}
+	// 保留原始的错误信息，同时仍然满足 nats.ErrNoServers
+	if !errors.Is(err, nats.ErrNoServers) || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("错误信息不匹配: %v", err)
+	}
+}
+
+// TestProxyFromEnvironment 测试默认只读取 ALL_PROXY，HTTP 代理变量需要显式启用
+func TestProxyFromEnvironment(t *testing.T) {
+	for _, name := range []string{"ALL_PROXY", "all_proxy", "HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HTTP_PROXY", "http://proxy.corp:3128")
+	if d := ProxyFromEnvironment(); d.URL != nil {
+		t.Errorf("默认不应读取 HTTP_PROXY: %v", d.URL)
+	}
+	if d := HTTPProxyFromEnvironment(); d.URL == nil || d.URL.Host != "proxy.corp:3128" {
+		t.Errorf("应读取 HTTP_PROXY: %v", d.URL)
+	}
+	t.Setenv("ALL_PROXY", "socks5://socks.corp:1080")
+	if d := HTTPProxyFromEnvironment(); d.URL == nil || d.URL.Scheme != "socks5" {
+		t.Errorf("ALL_PROXY 应优先: %v", d.URL)
+	}
+}
+
+// TestProxyBypass 测试回环地址与 NO_PROXY 中的地址直接连接
+func TestProxyBypass(t *testing.T) {
+	s := natstest.RunServer(t)
+	// 代理不可达，只有直连才能成功
+	nc, err := Connect(WithURL(s.ClientURL()), WithProxy("http://127.0.0.1:1", ""))
+	if err != nil {
+		t.Fatalf("回环地址应直连: %v", err)
+	}
+	nc.Close()
+
+	d, err := NewProxyDialer("socks5://proxy.corp:1080", "*.local, internal.example.com:4222, 10.0.0.0/8, 192.168.1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr   string
+		bypass bool
+	}{
+		{"nats.local:4222", true},
+		{"internal.example.com:4222", true},
+		{"a.internal.example.com:4222", true},
+		{"internal.example.com:7422", false},
+		{"10.1.2.3:4222", true},
+		{"192.168.1.5:4222", true},
+		{"192.168.1.6:4222", false},
+		{"nats.example.com:4222", false},
+		{"localhost:4222", true},
+		{"[::1]:4222", true},
+	}
+	for _, tt := range tests {
+		if got := d.bypass(tt.addr); got != tt.bypass {
+			t.Errorf("bypass(%q) = %v, want %v", tt.addr, got, tt.bypass)
+		}
+	}
+}
+
+// TestNoProxyFromConfig 测试代理来自环境变量时也使用配置的直连列表
+func TestNoProxyFromConfig(t *testing.T) {
+	t.Setenv("ALL_PROXY", "socks5://socks.corp:1080")
+	t.Setenv("NO_PROXY", "env.example.com")
+	t.Setenv("NATS_PROXY", "")
+	t.Setenv("NATS_NO_PROXY", "*.internal")
+
+	cfg, err := NewConfig(WithEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := applyOptions(t, cfg).CustomDialer.(*ProxyDialer)
+	if !ok || d.URL == nil || d.URL.Host != "socks.corp:1080" {
+		t.Fatalf("应使用 ALL_PROXY 中的代理: %+v", d)
+	}
+	if !d.bypass("nats.internal:4222") || d.bypass("env.example.com:4222") {
+		t.Errorf("NATS_NO_PROXY 应替代 NO_PROXY: %q", d.NoProxy)
+	}
+	if cfg.Dialer.(*ProxyDialer).NoProxy != "env.example.com" {
+		t.Error("不应修改 Dialer 字段中的拨号器")
+	}
+}
+
+// TestSOCKS5Proxy 测试经由需要认证的 SOCKS5 代理连接
+func TestSOCKS5Proxy(t *testing.T) {
+	s := natstest.RunServer(t)
+	addr, targets := runSOCKS5Proxy(t, s.Addr().String(), "bob", "pw")
+
+	nc, err := Connect(WithURL("nats://nats.internal:4222"), WithProxy("socks5://bob:pw@"+addr, ""))
+	if err != nil {
+		t.Fatalf("经由 SOCKS5 代理连接失败: %v", err)
+	}
+	defer nc.Close()
+	if got := <-targets; got != "nats.internal:4222" {
+		t.Errorf("代理收到的目标不匹配: %q", got)
+	}
+
+	_, err = Connect(WithURL("nats://nats.internal:4222"), WithProxy("socks5://bob:bad@"+addr, ""))
+	var pe *ProxyError
+	if !errors.As(err, &pe) {
+		t.Errorf("认证失败时期望 *ProxyError，实际: %v", err)
+	}
+}
+
+// TestProxyErrorEvent 测试重连时代理故障以独立的事件报告
+func TestProxyErrorEvent(t *testing.T) {
+	s := natstest.RunServer(t)
+	p := runConnectProxy(t, s.Addr().String(), "", "")
+	events := make(chan Event, 64)
+	nc, err := Connect(
+		WithURL("nats://nats.internal:4222"),
+		WithProxy("http://"+p.ln.Addr().String(), ""),
+		WithReconnect(-1, 20*time.Millisecond),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("经由代理连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	p.setFail(true)
+	p.dropAll()
+	e := waitEvent(t, events, EventProxyError)
+	var pe *ProxyError
+	if !errors.As(e.Err, &pe) || pe.StatusCode != http.StatusBadGateway {
+		t.Errorf("事件中的错误不匹配: %v", e.Err)
+	}
+
+	p.setFail(false)
+	waitEvent(t, events, EventReconnected)
+}
@@ -0,0 +1,158 @@
+package nats_client
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// EventType 连接生命周期事件类型
+type EventType string
+
+const (
+	EventConnected         EventType = "connected"          // 首次连接成功
+	EventDisconnected      EventType = "disconnected"       // 连接断开
+	EventReconnected       EventType = "reconnected"        // 重连成功
+	EventClosed            EventType = "closed"             // 连接关闭，不再重连
+	EventReconnectError    EventType = "reconnect_error"    // 一次重连尝试失败
+	EventProxyError        EventType = "proxy_error"        // 经由代理重连失败，Err 为 *ProxyError
+	EventLameDuck          EventType = "lame_duck"          // 服务器进入 lame duck 模式
+	EventDiscoveredServers EventType = "discovered_servers" // 发现新的集群节点
+	EventSlowConsumer      EventType = "slow_consumer"      // 订阅处理过慢，消息被丢弃
+	EventAsyncError        EventType = "async_error"        // 其他异步错误
+	EventConnectRetry      EventType = "connect_retry"      // ConnectContext 首次连接失败，等待后重试
+	EventFailover          EventType = "failover"           // Failover 切换到另一集群
+	EventFailback          EventType = "failback"           // Failover 切回主集群
+)
+
+// Event 连接生命周期事件
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	Name     string        // 连接名称
+	Cluster  string        // 集群名称，仅 Failover 管理的连接
+	Server   string        // 当前服务器地址，已隐藏密码
+	Servers  []string      // 发现的服务器列表，仅 EventDiscoveredServers
+	Subject  string        // 相关订阅的主题，仅 EventSlowConsumer 与 EventAsyncError
+	Stream   string        // 相关的流，仅 Failover 重建消费者失败
+	Consumer string        // 相关的消费者，仅 Failover 重建消费者失败
+	Attempt  int           // 第几次连接尝试，仅 EventConnectRetry
+	Delay    time.Duration // 下次重试前的等待时间，仅 EventConnectRetry
+	Err      error
+}
+
+// String 返回便于记录日志的描述
+func (e Event) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s server=%s", e.Type, e.Server)
+	if e.Cluster != "" {
+		fmt.Fprintf(&b, " cluster=%s", e.Cluster)
+	}
+	if e.Subject != "" {
+		fmt.Fprintf(&b, " subject=%s", e.Subject)
+	}
+	if e.Stream != "" {
+		fmt.Fprintf(&b, " stream=%s consumer=%s", e.Stream, e.Consumer)
+	}
+	if e.Attempt > 0 {
+		fmt.Fprintf(&b, " attempt=%d delay=%v", e.Attempt, e.Delay)
+	}
+	if len(e.Servers) > 0 {
+		fmt.Fprintf(&b, " servers=%s", strings.Join(e.Servers, ","))
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, " err=%v", e.Err)
+	}
+	return b.String()
+}
+
+// EventHandler 处理生命周期事件，在 nats 的回调协程中同步调用，不应阻塞
+type EventHandler func(Event)
+
+// LogEvent 将事件写入 slog.Default()。Config 已经通过 Logger 记录所有事件，
+// 只有在其他地方转发事件时才需要使用
+func LogEvent(e Event) {
+	logEvent(slog.Default(), e)
+}
+
+// WithEventHandler 追加事件处理器
+func WithEventHandler(h EventHandler) Option {
+	return func(c *Config) error {
+		c.EventHandlers = append(c.EventHandlers, h)
+		return nil
+	}
+}
+
+// WithEventChannel 将事件发送到 ch，通道已满时丢弃事件以免阻塞连接
+func WithEventChannel(ch chan<- Event) Option {
+	return WithEventHandler(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+}
+
+// emit 补全事件的时间与连接信息后交给所有处理器
+func (c *Config) emit(nc *nats.Conn, e Event) {
+	e.Time = time.Now()
+	if nc != nil {
+		e.Name = nc.Opts.Name
+		if e.Server == "" {
+			e.Server = nc.ConnectedUrlRedacted()
+		}
+	} else if e.Name == "" {
+		e.Name = c.Name
+	}
+	logEvent(logger(c.Logger), e)
+	for _, h := range c.EventHandlers {
+		h(e)
+	}
+}
+
+// eventOptions 将 nats 的各类回调转换为 Event
+func (c *Config) eventOptions() []nats.Option {
+	emit := c.emit
+	return []nats.Option{
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			emit(nc, Event{Type: EventConnected})
+		}),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			emit(nc, Event{Type: EventDisconnected, Err: err})
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			emit(nc, Event{Type: EventReconnected})
+		}),
+		nats.ReconnectErrHandler(func(nc *nats.Conn, err error) {
+			e := Event{Type: EventReconnectError, Err: err}
+			var pe *ProxyError
+			if errors.As(err, &pe) {
+				e.Type = EventProxyError
+			}
+			emit(nc, e)
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			emit(nc, Event{Type: EventClosed, Err: nc.LastError()})
+		}),
+		nats.LameDuckModeHandler(func(nc *nats.Conn) {
+			emit(nc, Event{Type: EventLameDuck})
+		}),
+		nats.DiscoveredServersHandler(func(nc *nats.Conn) {
+			emit(nc, Event{Type: EventDiscoveredServers, Servers: nc.DiscoveredServers()})
+		}),
+		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
+			e := Event{Type: EventAsyncError, Err: err}
+			if errors.Is(err, nats.ErrSlowConsumer) {
+				e.Type = EventSlowConsumer
+			}
+			if sub != nil {
+				e.Subject = sub.Subject
+			}
+			emit(nc, e)
+		}),
+	}
+}
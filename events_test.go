@@ -0,0 +1,174 @@
+package nats_client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// waitEvent 等待指定类型的事件
+func waitEvent(t *testing.T, ch <-chan Event, typ EventType) Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == typ {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("等待事件 %s 超时", typ)
+			return Event{}
+		}
+	}
+}
+
+// TestLifecycleEvents 测试连接、断开、重连与关闭事件
+func TestLifecycleEvents(t *testing.T) {
+	s := natstest.RunServer(t, natstest.WithoutJetStream())
+	port := s.Addr().(*net.TCPAddr).Port
+	url := s.ClientURL()
+
+	events := make(chan Event, 64)
+	nc, err := Connect(
+		WithURL(url),
+		WithName("events-test"),
+		WithDialer(nil),
+		WithReconnect(-1, 50*time.Millisecond),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	e := waitEvent(t, events, EventConnected)
+	if e.Name != "events-test" || e.Server == "" || e.Time.IsZero() {
+		t.Errorf("连接事件字段不完整: %+v", e)
+	}
+
+	s.Shutdown()
+	waitEvent(t, events, EventDisconnected)
+
+	natstest.RunServer(t, natstest.WithoutJetStream(), natstest.WithPort(port))
+	e = waitEvent(t, events, EventReconnected)
+	if e.Server == "" {
+		t.Errorf("重连事件缺少服务器地址: %+v", e)
+	}
+
+	nc.Close()
+	waitEvent(t, events, EventClosed)
+}
+
+// TestSlowConsumerEvent 测试慢消费者事件带有订阅主题
+func TestSlowConsumerEvent(t *testing.T) {
+	s := natstest.RunServer(t, natstest.WithoutJetStream())
+	events := make(chan Event, 64)
+	nc, err := Connect(WithURL(s.ClientURL()), WithDialer(nil), WithEventChannel(events))
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	block := make(chan struct{})
+	defer close(block)
+	sub, err := nc.Subscribe("events.slow", func(*nats.Msg) { <-block })
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	if err := sub.SetPendingLimits(1, -1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		nc.Publish("events.slow", []byte("x"))
+	}
+	nc.Flush()
+
+	e := waitEvent(t, events, EventSlowConsumer)
+	if e.Subject != "events.slow" || !errors.Is(e.Err, nats.ErrSlowConsumer) {
+		t.Errorf("慢消费者事件不匹配: %+v", e)
+	}
+}
+
+// TestLameDuckEvent 测试服务器进入 lame duck 模式时的事件
+func TestLameDuckEvent(t *testing.T) {
+	s := natstest.RunServer(t, natstest.WithoutJetStream(), func(o *server.Options) {
+		o.LameDuckDuration = time.Second
+		o.LameDuckGracePeriod = 10 * time.Millisecond
+	})
+
+	events := make(chan Event, 64)
+	nc, err := Connect(WithURL(s.ClientURL()), WithDialer(nil), WithEventChannel(events))
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	go s.LameDuckShutdown()
+	waitEvent(t, events, EventLameDuck)
+}
+
+// TestEventLogger 测试事件以统一的键写入 Logger
+func TestEventLogger(t *testing.T) {
+	s := natstest.RunServer(t, natstest.WithoutJetStream())
+	var buf syncBuffer
+	events := make(chan Event, 8)
+	nc, err := Connect(
+		WithURL(s.ClientURL()),
+		WithName("log-test"),
+		WithDialer(nil),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	// 处理器在写入日志之后调用
+	waitEvent(t, events, EventConnected)
+
+	var rec map[string]any
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		t.Fatalf("日志不是 JSON: %q", buf.String())
+	}
+	if rec["msg"] != "nats event" || rec["event"] != "connected" || rec[LogKeyConn] != "log-test" || rec[LogKeyServer] == nil {
+		t.Errorf("日志字段不匹配: %v", rec)
+	}
+
+	e := Event{Type: EventAsyncError, Stream: "ORDERS", Consumer: "billing", Err: errors.New("boom")}
+	if e.level() != slog.LevelError {
+		t.Errorf("异步错误应为 Error 级别: %v", e.level())
+	}
+	if got := e.String(); !strings.Contains(got, "stream=ORDERS consumer=billing") {
+		t.Errorf("事件描述缺少流与消费者: %s", got)
+	}
+}
+
+// syncBuffer 并发安全的 bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
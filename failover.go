@@ -0,0 +1,501 @@
+package nats_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// FailoverCluster 一个可供切换的集群，Options 在共享选项之后应用，通常包含 WithURL
+type FailoverCluster struct {
+	Name    string
+	Options []Option
+}
+
+// FailoverConfig 主备集群切换参数
+type FailoverConfig struct {
+	Primary   FailoverCluster
+	Secondary FailoverCluster
+	// UnhealthyAfter 当前集群连续断开超过该时间后切换，默认 10s
+	UnhealthyAfter time.Duration
+	// FailbackAfter 主集群恢复并持续可用该时间后切回，默认 30s
+	FailbackAfter time.Duration
+	// CheckInterval 健康检查间隔，默认 1s
+	CheckInterval time.Duration
+}
+
+// Failover 优先使用主集群的连接管理器。当前集群不可用时切换到另一集群，
+// 主集群恢复后切回，并在新连接上重建订阅与 JetStream 消费者
+type Failover struct {
+	cfg      FailoverConfig
+	clusters [2]*Config
+
+	mu        sync.Mutex
+	active    int // 0 主集群，1 备集群
+	conn      *nats.Conn
+	js        *JetStream
+	since     time.Time
+	switches  int
+	subs      map[*FailoverSubscription]struct{}
+	consumers map[*FailoverConsumer]struct{}
+
+	probe  *nats.Conn // 切换到备集群后探测主集群的连接
+	stop   chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// NewFailover 建立到主集群的连接，主集群不可用时直接使用备集群。
+// opts 由两个集群共享
+func NewFailover(cfg FailoverConfig, opts ...Option) (*Failover, error) {
+	if cfg.UnhealthyAfter <= 0 {
+		cfg.UnhealthyAfter = 10 * time.Second
+	}
+	if cfg.FailbackAfter <= 0 {
+		cfg.FailbackAfter = 30 * time.Second
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = time.Second
+	}
+	f := &Failover{
+		cfg:       cfg,
+		subs:      make(map[*FailoverSubscription]struct{}),
+		consumers: make(map[*FailoverConsumer]struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for i, cl := range []FailoverCluster{cfg.Primary, cfg.Secondary} {
+		c, err := NewConfig(append(append([]Option(nil), opts...), cl.Options...)...)
+		if err != nil {
+			return nil, fmt.Errorf("nats: cluster %s: %w", cl.Name, err)
+		}
+		// 事件中附带集群名称
+		handlers, name := c.EventHandlers, cl.Name
+		c.EventHandlers = []EventHandler{func(e Event) {
+			if e.Cluster == "" {
+				e.Cluster = name
+			}
+			for _, h := range handlers {
+				h(e)
+			}
+		}}
+		f.clusters[i] = c
+	}
+
+	var errs []error
+	for i := range f.clusters {
+		nc, err := f.clusters[i].Connect()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.clusterName(i), err))
+			continue
+		}
+		f.use(i, nc) // 集群未启用 JetStream 时只使用核心连接，错误已经产生事件
+		if m := f.clusters[0].Metrics; m != nil {
+			m.registerFailover(f)
+		}
+		go f.run()
+		return f, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (f *Failover) clusterName(i int) string {
+	if i == 0 {
+		return f.cfg.Primary.Name
+	}
+	return f.cfg.Secondary.Name
+}
+
+// Conn 返回当前集群的连接，切换后会变化，不应长期保存
+func (f *Failover) Conn() *nats.Conn {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conn
+}
+
+// JetStream 返回当前集群的 JetStream 客户端，集群未启用 JetStream 时返回 nil
+func (f *Failover) JetStream() *JetStream {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.js
+}
+
+// ActiveCluster 返回当前使用的集群名称
+func (f *Failover) ActiveCluster() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.clusterName(f.active)
+}
+
+// FailoverStats 切换状态
+type FailoverStats struct {
+	Active   string    // 当前集群名称
+	Primary  bool      // 当前是否为主集群
+	Since    time.Time // 切换到当前集群的时间
+	Switches int       // 累计切换次数
+}
+
+// Stats 返回当前集群与切换次数
+func (f *Failover) Stats() FailoverStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return FailoverStats{
+		Active:   f.clusterName(f.active),
+		Primary:  f.active == 0,
+		Since:    f.since,
+		Switches: f.switches,
+	}
+}
+
+// use 切换到集群 i 的连接 nc，在新连接上重建订阅与消费者，返回旧连接。
+// 创建 JetStream 客户端与消费者需要访问服务器，都在 f.mu 之外完成，持锁时只交换指针。
+// 集群未启用 JetStream 时只保留核心连接，产生事件并返回创建 JetStream 客户端的错误
+func (f *Failover) use(i int, nc *nats.Conn) (old *nats.Conn, err error) {
+	c := f.clusters[i]
+	js, err := NewJetStream(context.Background(), nc, c.JetStream)
+	if err != nil {
+		js = nil
+		c.emit(nc, Event{Type: EventAsyncError, Err: fmt.Errorf("jetstream on %s: %w", f.clusterName(i), err)})
+	} else {
+		js.metrics = c.Metrics
+	}
+
+	// 交换连接后新的订阅与消费者直接建立在新连接上，这里只重建已有的
+	f.mu.Lock()
+	old = f.conn
+	f.active, f.conn, f.js, f.since = i, nc, js, time.Now()
+	subs := slices.Collect(maps.Keys(f.subs))
+	consumers := slices.Collect(maps.Keys(f.consumers))
+	f.mu.Unlock()
+
+	for _, s := range subs {
+		if err := s.rebind(i, nc); err != nil {
+			c.emit(nc, Event{Type: EventAsyncError, Subject: s.subject, Err: err})
+		}
+	}
+	for _, cons := range consumers {
+		if err := cons.rebind(js); err != nil {
+			c.emit(nc, Event{Type: EventAsyncError, Stream: cons.stream, Consumer: cons.cfg.Durable, Err: err})
+		}
+	}
+	return old, err
+}
+
+// probeConfig 探测主集群使用的配置：不登记指标、不产生事件，也不监视凭证与证书文件
+func (f *Failover) probeConfig() *Config {
+	c := *f.clusters[0]
+	c.Metrics, c.EventHandlers = nil, nil
+	c.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	c.CredsWatchInterval, c.TLSWatchInterval = 0, 0
+	return &c
+}
+
+// run 定期检查当前集群与主集群的健康状态
+func (f *Failover) run() {
+	defer close(f.done)
+	t := time.NewTicker(f.cfg.CheckInterval)
+	defer t.Stop()
+	var unhealthySince, healthySince time.Time
+	probe := f.probeConfig()
+	// 探测连接在单独的 goroutine 中建立，连接超时不会推迟健康检查
+	dialed := make(chan *nats.Conn, 1)
+	dialing := false
+	defer func() {
+		if dialing {
+			go closeConn(<-dialed)
+		}
+	}()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case pc := <-dialed:
+			dialing = false
+			f.mu.Lock()
+			active := f.active
+			f.mu.Unlock()
+			// 拨号期间已经切回主集群时不再需要探测
+			if active == 0 {
+				closeConn(pc)
+			} else if pc != nil {
+				f.probe, healthySince = pc, time.Now()
+			}
+			continue
+		case <-t.C:
+		}
+		f.mu.Lock()
+		active, nc := f.active, f.conn
+		f.mu.Unlock()
+
+		now := time.Now()
+		if nc.IsConnected() {
+			unhealthySince = time.Time{}
+		} else if unhealthySince.IsZero() {
+			unhealthySince = now
+		} else if now.Sub(unhealthySince) >= f.cfg.UnhealthyAfter {
+			if active == 1 {
+				f.closeProbe()
+			}
+			if f.switchTo(1-active, EventFailover) {
+				unhealthySince, healthySince = time.Time{}, time.Time{}
+				continue
+			}
+		}
+
+		if active == 0 {
+			continue
+		}
+		// 在备集群上时探测主集群，恢复并持续可用 FailbackAfter 后切回
+		if f.probe == nil {
+			if !dialing {
+				dialing = true
+				go func() {
+					pc, _ := probe.Connect()
+					dialed <- pc
+				}()
+			}
+			continue
+		}
+		if !f.probe.IsConnected() {
+			healthySince = time.Time{}
+			continue
+		}
+		if healthySince.IsZero() {
+			healthySince = now
+		}
+		if now.Sub(healthySince) >= f.cfg.FailbackAfter {
+			f.closeProbe()
+			f.switchTo(0, EventFailback)
+			unhealthySince, healthySince = time.Time{}, time.Time{}
+		}
+	}
+}
+
+// closeProbe 关闭探测连接。切回主集群时使用新建的连接，探测连接没有登记指标与事件
+func (f *Failover) closeProbe() {
+	closeConn(f.probe)
+	f.probe = nil
+}
+
+func closeConn(nc *nats.Conn) {
+	if nc != nil {
+		nc.Close()
+	}
+}
+
+// switchTo 新建到集群 i 的连接并切换，成功后关闭旧连接并产生事件
+func (f *Failover) switchTo(i int, typ EventType) bool {
+	nc, err := f.clusters[i].Connect()
+	if err != nil {
+		f.clusters[1-i].emit(nil, Event{Type: EventAsyncError, Err: fmt.Errorf("failover to %s: %w", f.clusterName(i), err)})
+		return false
+	}
+	old, _ := f.use(i, nc) // JetStream 的错误已经产生事件，核心连接仍然可用
+	f.mu.Lock()
+	f.switches++
+	f.mu.Unlock()
+
+	f.clusters[i].emit(nc, Event{Type: typ})
+	if old != nil {
+		if typ == EventFailback {
+			// 备集群仍然可用，处理完已收到的消息后关闭
+			old.Drain()
+		} else {
+			old.Close()
+		}
+	}
+	return true
+}
+
+func (f *Failover) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// Close 停止健康检查并关闭所有连接
+func (f *Failover) Close() {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	f.mu.Unlock()
+	close(f.stop)
+	<-f.done
+	f.closeProbe()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for cons := range f.consumers {
+		if cons.cc != nil {
+			cons.cc.Stop()
+			cons.cc = nil
+		}
+	}
+	f.conn.Close()
+}
+
+// FailoverSubscription 跨集群切换保持的订阅
+type FailoverSubscription struct {
+	f       *Failover
+	subject string
+	queue   string
+	cb      nats.MsgHandler
+	sub     *nats.Subscription
+}
+
+// Subscribe 订阅 subject，切换集群后自动在新连接上重新订阅
+func (f *Failover) Subscribe(subject string, cb nats.MsgHandler) (*FailoverSubscription, error) {
+	return f.QueueSubscribe(subject, "", cb)
+}
+
+// QueueSubscribe 以队列组 queue 订阅 subject，切换集群后自动重新订阅
+func (f *Failover) QueueSubscribe(subject, queue string, cb nats.MsgHandler) (*FailoverSubscription, error) {
+	s := &FailoverSubscription{f: f, subject: subject, queue: queue, cb: cb}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := s.bind(f.conn); err != nil {
+		return nil, err
+	}
+	f.subs[s] = struct{}{}
+	return s, nil
+}
+
+// bind 在当前连接 nc 上建立订阅，调用方持有 f.mu
+func (s *FailoverSubscription) bind(nc *nats.Conn) error {
+	sub, err := nc.QueueSubscribe(s.subject, s.queue, s.cb)
+	if err != nil {
+		return err
+	}
+	s.sub = sub
+	if m := s.f.clusters[s.f.active].Metrics; m != nil {
+		m.Track(sub)
+	}
+	return nil
+}
+
+// rebind 切换后在集群 i 的连接 nc 上重新订阅，期间已经退订时不再保留。
+// 旧连接随后关闭，这里不需要退订旧的订阅
+func (s *FailoverSubscription) rebind(i int, nc *nats.Conn) error {
+	sub, err := nc.QueueSubscribe(s.subject, s.queue, s.cb)
+	f := s.f
+	f.mu.Lock()
+	_, ok := f.subs[s]
+	if ok {
+		s.sub = sub
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		sub.Unsubscribe()
+		return nil
+	}
+	if m := f.clusters[i].Metrics; m != nil {
+		m.Track(sub)
+	}
+	return nil
+}
+
+// Unsubscribe 取消订阅，之后切换集群不再重建
+func (s *FailoverSubscription) Unsubscribe() error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	delete(s.f.subs, s)
+	if s.sub == nil {
+		return nil
+	}
+	return s.sub.Unsubscribe()
+}
+
+// FailoverConsumer 跨集群切换保持的 JetStream 消费者
+type FailoverConsumer struct {
+	f       *Failover
+	stream  string
+	cfg     jetstream.ConsumerConfig
+	handler jetstream.MessageHandler
+	cc      jetstream.ConsumeContext
+}
+
+// Consume 在当前集群上创建或更新 stream 的消费者并开始消费，
+// 切换集群后在新集群上以相同配置重建。两个集群需要有同名的流
+func (f *Failover) Consume(stream string, cfg jetstream.ConsumerConfig, handler jetstream.MessageHandler) (*FailoverConsumer, error) {
+	c := &FailoverConsumer{f: f, stream: stream, cfg: cfg, handler: handler}
+	for {
+		f.mu.Lock()
+		js, active := f.js, f.active
+		f.mu.Unlock()
+		if js == nil {
+			return nil, fmt.Errorf("%w (cluster %s)", ErrJetStreamUnavailable, f.clusterName(active))
+		}
+		// 创建消费者需要访问服务器，不持有 f.mu
+		cc, err := js.Consume(context.Background(), stream, cfg, handler)
+		if err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		if f.js == js {
+			c.cc = cc
+			f.consumers[c] = struct{}{}
+			f.mu.Unlock()
+			return c, nil
+		}
+		f.mu.Unlock()
+		// 期间切换了集群，在新集群上重建
+		cc.Stop()
+	}
+}
+
+// rebind 停止旧的消费并在 js 上重建，js 为 nil 时只停止，期间已经停止时不再保留
+func (c *FailoverConsumer) rebind(js *JetStream) error {
+	f := c.f
+	f.mu.Lock()
+	_, ok := f.consumers[c]
+	old := c.cc
+	c.cc = nil
+	f.mu.Unlock()
+	if old != nil {
+		old.Stop()
+	}
+	if !ok {
+		return nil
+	}
+	if js == nil {
+		return ErrJetStreamUnavailable
+	}
+	cc, err := js.Consume(context.Background(), c.stream, c.cfg, c.handler)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	if _, ok = f.consumers[c]; ok {
+		c.cc = cc
+	}
+	f.mu.Unlock()
+	if !ok {
+		cc.Stop()
+	}
+	return nil
+}
+
+// Stop 停止消费，之后切换集群不再重建。服务器上的消费者保留
+func (c *FailoverConsumer) Stop() {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	delete(c.f.consumers, c)
+	if c.cc != nil {
+		c.cc.Stop()
+		c.cc = nil
+	}
+}
@@ -0,0 +1,199 @@
+package nats_client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// TestFailover 测试主集群停止后切换到备集群，恢复后切回，
+// 订阅与 JetStream 消费者在每次切换后重建
+func TestFailover(t *testing.T) {
+	port, store := natstest.FreePort(t), t.TempDir()
+	primary := natstest.RunServer(t, natstest.WithPort(port), natstest.WithStoreDir(store))
+	secondary := natstest.RunServer(t)
+	for _, s := range []string{primary.ClientURL(), secondary.ClientURL()} {
+		createFailoverStream(t, s)
+	}
+
+	events := make(chan Event, 256)
+	f, err := NewFailover(FailoverConfig{
+		Primary:        FailoverCluster{Name: "east", Options: []Option{WithURL(primary.ClientURL())}},
+		Secondary:      FailoverCluster{Name: "west", Options: []Option{WithURL(secondary.ClientURL())}},
+		UnhealthyAfter: 200 * time.Millisecond,
+		FailbackAfter:  200 * time.Millisecond,
+		CheckInterval:  20 * time.Millisecond,
+	}, WithDialer(nil), WithReconnect(-1, 50*time.Millisecond), WithJetStreamDomain(natstest.Domain), WithEventChannel(events))
+	if err != nil {
+		t.Fatalf("创建 Failover 失败: %v", err)
+	}
+	defer f.Close()
+	if f.ActiveCluster() != "east" {
+		t.Fatalf("初始应使用主集群: %s", f.ActiveCluster())
+	}
+
+	received := make(chan string, 16)
+	if _, err := f.Subscribe("fo.core", func(m *nats.Msg) { received <- "core:" + string(m.Data) }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Consume("FO", jetstream.ConsumerConfig{Durable: "worker"}, func(m jetstream.Msg) {
+		m.Ack()
+		received <- "js:" + string(m.Data())
+	}); err != nil {
+		t.Fatalf("创建消费者失败: %v", err)
+	}
+
+	// 从集群外部发布，验证当前集群上的订阅与消费者
+	expect := func(url, data string) {
+		t.Helper()
+		nc, err := nats.Connect(url, nats.Name("publisher"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer nc.Close()
+		nc.Publish("fo.core", []byte(data))
+		js, _ := jetstream.NewWithDomain(nc, natstest.Domain)
+		if _, err := js.Publish(context.Background(), "fo.js", []byte(data)); err != nil {
+			t.Fatalf("发布失败: %v", err)
+		}
+		want := map[string]bool{"core:" + data: true, "js:" + data: true}
+		for len(want) > 0 {
+			select {
+			case got := <-received:
+				delete(want, got)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("等待消息超时，缺少 %v", want)
+			}
+		}
+	}
+	expect(primary.ClientURL(), "1")
+
+	primary.Shutdown()
+	primary.WaitForShutdown()
+	e := waitEvent(t, events, EventFailover)
+	if e.Cluster != "west" || f.ActiveCluster() != "west" {
+		t.Fatalf("应切换到备集群: event=%v active=%s", e, f.ActiveCluster())
+	}
+	expect(secondary.ClientURL(), "2")
+
+	natstest.RunServer(t, natstest.WithPort(port), natstest.WithStoreDir(store))
+	e = waitEvent(t, events, EventFailback)
+	if e.Cluster != "east" || f.ActiveCluster() != "east" {
+		t.Fatalf("应切回主集群: event=%v active=%s", e, f.ActiveCluster())
+	}
+	expect(fmt.Sprintf("nats://127.0.0.1:%d", port), "3")
+
+	if st := f.Stats(); st.Switches != 2 || !st.Primary {
+		t.Errorf("切换统计不匹配: %+v", st)
+	}
+}
+
+// TestFailoverStartOnSecondary 测试主集群不可用时直接使用备集群
+func TestFailoverStartOnSecondary(t *testing.T) {
+	secondary := natstest.RunServer(t)
+	f, err := NewFailover(FailoverConfig{
+		Primary:   FailoverCluster{Name: "east", Options: []Option{WithURL(fmt.Sprintf("nats://127.0.0.1:%d", natstest.FreePort(t)))}},
+		Secondary: FailoverCluster{Name: "west", Options: []Option{WithURL(secondary.ClientURL())}},
+	}, WithDialer(nil))
+	if err != nil {
+		t.Fatalf("创建 Failover 失败: %v", err)
+	}
+	defer f.Close()
+	if st := f.Stats(); st.Active != "west" || st.Primary {
+		t.Errorf("应使用备集群: %+v", st)
+	}
+}
+
+// TestFailoverProbe 测试在备集群上探测主集群的连接不登记指标、不产生事件
+func TestFailoverProbe(t *testing.T) {
+	port := natstest.FreePort(t)
+	secondary := natstest.RunServer(t)
+	m, events := NewMetrics(), make(chan Event, 256)
+	f, err := NewFailover(FailoverConfig{
+		Primary:       FailoverCluster{Name: "east", Options: []Option{WithURL(fmt.Sprintf("nats://127.0.0.1:%d", port))}},
+		Secondary:     FailoverCluster{Name: "west", Options: []Option{WithURL(secondary.ClientURL())}},
+		FailbackAfter: time.Hour,
+		CheckInterval: 20 * time.Millisecond,
+	}, WithDialer(nil), WithMetrics(m), WithEventChannel(events))
+	if err != nil {
+		t.Fatalf("创建 Failover 失败: %v", err)
+	}
+	defer f.Close()
+
+	natstest.RunServer(t, natstest.WithPort(port))
+	time.Sleep(300 * time.Millisecond)
+	for len(events) > 0 {
+		if e := <-events; e.Cluster == "east" {
+			t.Errorf("探测连接不应产生事件: %v", e)
+		}
+	}
+	m.mu.Lock()
+	conns := len(m.conns)
+	m.mu.Unlock()
+	if conns != 1 {
+		t.Errorf("指标中应只登记备集群的连接，实际 %d 个", conns)
+	}
+}
+
+// TestFailoverProbeClosed 测试备集群不可用切回主集群时关闭探测连接
+func TestFailoverProbeClosed(t *testing.T) {
+	port := natstest.FreePort(t)
+	secondary := natstest.RunServer(t)
+	events := make(chan Event, 256)
+	f, err := NewFailover(FailoverConfig{
+		Primary:        FailoverCluster{Name: "east", Options: []Option{WithURL(fmt.Sprintf("nats://127.0.0.1:%d", port))}},
+		Secondary:      FailoverCluster{Name: "west", Options: []Option{WithURL(secondary.ClientURL())}},
+		UnhealthyAfter: 100 * time.Millisecond,
+		FailbackAfter:  time.Hour,
+		CheckInterval:  20 * time.Millisecond,
+	}, WithDialer(nil), WithReconnect(-1, 20*time.Millisecond), WithEventChannel(events))
+	if err != nil {
+		t.Fatalf("创建 Failover 失败: %v", err)
+	}
+	defer f.Close()
+
+	primary := natstest.RunServer(t, natstest.WithPort(port))
+	waitClients(t, primary, 1) // 探测连接
+	secondary.Shutdown()
+	if e := waitEvent(t, events, EventFailover); e.Cluster != "east" {
+		t.Fatalf("期望切换到 east，实际 %s", e.Cluster)
+	}
+	waitClients(t, primary, 1)
+	time.Sleep(100 * time.Millisecond)
+	if n := primary.NumClients(); n != 1 {
+		t.Errorf("切回主集群后探测连接未关闭，主集群上有 %d 个连接", n)
+	}
+}
+
+func waitClients(t *testing.T, s *server.Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.NumClients() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待 %d 个连接超时，实际 %d 个", n, s.NumClients())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func createFailoverStream(t *testing.T, url string) {
+	t.Helper()
+	nc, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.NewWithDomain(nc, natstest.Domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "FO", Subjects: []string{"fo.js"}}); err != nil {
+		t.Fatalf("创建流失败: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package nats_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// HealthConfig 就绪检查的参数
+type HealthConfig struct {
+	// MaxRTT 往返时间超过该值时不就绪，默认 1s
+	MaxRTT time.Duration
+	// Timeout 单次就绪检查的总超时，默认 5s
+	Timeout time.Duration
+	// Streams 必须存在的流
+	Streams []string
+	// KeyValueBuckets 必须存在的 KV 桶
+	KeyValueBuckets []string
+	// ObjectBuckets 必须存在的对象存储桶
+	ObjectBuckets []string
+}
+
+// 检查结果状态
+const (
+	HealthOK      = "ok"
+	HealthFail    = "fail"
+	HealthSkipped = "skipped" // 连接不可用时跳过的检查
+)
+
+// HealthCheck 单项检查的结果
+type HealthCheck struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Detail     string  `json:"detail,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// HealthReport 一次检查的汇总，Status 为 ok 表示全部检查通过
+type HealthReport struct {
+	Status string        `json:"status"`
+	Time   time.Time     `json:"time"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// Health 提供 Kubernetes 存活与就绪探针。存活只表示进程在运行；
+// 就绪依次检查连接状态、往返时间、JetStream 域是否可达以及必需的流与桶
+type Health struct {
+	cfg   HealthConfig
+	conn  func() *nats.Conn
+	js    func() *JetStream
+	start time.Time
+}
+
+// NewHealth 创建 nc 的健康检查，js 为 nil 时不检查 JetStream 与流、桶
+func NewHealth(nc *nats.Conn, js *JetStream, cfg HealthConfig) *Health {
+	return newHealth(func() *nats.Conn { return nc }, func() *JetStream { return js }, cfg)
+}
+
+// Health 创建检查 Failover 当前集群的健康检查
+func (f *Failover) Health(cfg HealthConfig) *Health {
+	return newHealth(f.Conn, f.JetStream, cfg)
+}
+
+func newHealth(conn func() *nats.Conn, js func() *JetStream, cfg HealthConfig) *Health {
+	if cfg.MaxRTT <= 0 {
+		cfg.MaxRTT = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &Health{cfg: cfg, conn: conn, js: js, start: time.Now()}
+}
+
+// Check 执行全部就绪检查。连接不可用时其余检查标记为 skipped
+func (h *Health) Check(ctx context.Context) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+	nc, js := h.conn(), h.js()
+	r := HealthReport{Status: HealthOK, Time: time.Now()}
+	add := func(c HealthCheck) {
+		if c.Status == HealthFail {
+			r.Status = HealthFail
+		}
+		r.Checks = append(r.Checks, c)
+	}
+	run := func(name string, fn func() (string, error)) {
+		start := time.Now()
+		detail, err := fn()
+		c := HealthCheck{Name: name, Status: HealthOK, Detail: detail, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			c.Status, c.Error = HealthFail, err.Error()
+		}
+		add(c)
+	}
+
+	connected := nc != nil && nc.IsConnected()
+	run("connected", func() (string, error) {
+		if nc == nil {
+			return "", errors.New("no connection")
+		}
+		if !connected {
+			return "", fmt.Errorf("connection %s", strings.ToLower(nc.Status().String()))
+		}
+		return nc.ConnectedUrlRedacted(), nil
+	})
+
+	names := []string{"rtt"}
+	if js != nil {
+		names = append(names, "jetstream")
+		for _, s := range h.cfg.Streams {
+			names = append(names, "stream:"+s)
+		}
+		for _, b := range h.cfg.KeyValueBuckets {
+			names = append(names, "kv:"+b)
+		}
+		for _, b := range h.cfg.ObjectBuckets {
+			names = append(names, "object:"+b)
+		}
+	}
+	if !connected {
+		for _, name := range names {
+			add(HealthCheck{Name: name, Status: HealthSkipped})
+		}
+		return r
+	}
+
+	run("rtt", func() (string, error) {
+		start := time.Now()
+		if err := nc.FlushWithContext(ctx); err != nil {
+			return "", err
+		}
+		rtt := time.Since(start)
+		if rtt > h.cfg.MaxRTT {
+			return rtt.String(), fmt.Errorf("rtt %v exceeds %v", rtt, h.cfg.MaxRTT)
+		}
+		return rtt.String(), nil
+	})
+	if js == nil {
+		return r
+	}
+	run("jetstream", func() (string, error) {
+		target := "default domain"
+		if js.Config.Domain != "" {
+			target = "domain " + js.Config.Domain
+		}
+		return target, js.Validate(ctx)
+	})
+	for _, s := range h.cfg.Streams {
+		run("stream:"+s, func() (string, error) {
+			_, err := js.Stream(ctx, s)
+			return "", err
+		})
+	}
+	for _, b := range h.cfg.KeyValueBuckets {
+		run("kv:"+b, func() (string, error) {
+			_, err := js.KeyValue(ctx, b)
+			return "", err
+		})
+	}
+	for _, b := range h.cfg.ObjectBuckets {
+		run("object:"+b, func() (string, error) {
+			_, err := js.ObjectStore(ctx, b)
+			return "", err
+		})
+	}
+	return r
+}
+
+// Live 存活探针，进程运行即返回 200
+func (h *Health) Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, struct {
+			HealthReport
+			Uptime string `json:"uptime"`
+		}{HealthReport{Status: HealthOK, Time: time.Now()}, time.Since(h.start).Round(time.Second).String()}, http.StatusOK)
+	})
+}
+
+// Ready 就绪探针，全部检查通过返回 200，否则返回 503，响应体为 HealthReport
+func (h *Health) Ready() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context())
+		code := http.StatusOK
+		if report.Status != HealthOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(w, report, code)
+	})
+}
+
+// ServeHTTP 路径以 /livez 结尾时为存活探针，其余为就绪探针
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/livez") {
+		h.Live().ServeHTTP(w, r)
+		return
+	}
+	h.Ready().ServeHTTP(w, r)
+}
+
+func writeHealth(w http.ResponseWriter, v any, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,99 @@
+package nats_client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// probe 请求探针并解析 JSON 响应
+func probe(t *testing.T, h http.Handler, path string) (int, HealthReport) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type 不匹配: %s", ct)
+	}
+	var r HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+		t.Fatalf("响应不是 JSON: %q", rec.Body.String())
+	}
+	return rec.Code, r
+}
+
+func checkStatus(r HealthReport) map[string]string {
+	m := make(map[string]string)
+	for _, c := range r.Checks {
+		m[c.Name] = c.Status
+	}
+	return m
+}
+
+// TestHealth 测试就绪检查覆盖连接、JetStream 与必需的流和桶，断开后不就绪而存活不受影响
+func TestHealth(t *testing.T) {
+	s := natstest.RunServer(t)
+	events := make(chan Event, 64)
+	cfg, err := NewConfig(WithURL(s.ClientURL()), WithDialer(nil), WithJetStreamDomain(natstest.Domain), WithReconnect(-1, 0), WithEventChannel(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	nc, js, err := cfg.ConnectJetStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	h := NewHealth(nc, js, HealthConfig{
+		Streams:         []string{"ORDERS"},
+		KeyValueBuckets: []string{"settings"},
+		ObjectBuckets:   []string{"files"},
+	})
+
+	code, r := probe(t, h, "/readyz")
+	st := checkStatus(r)
+	if code != http.StatusServiceUnavailable || st["stream:ORDERS"] != HealthFail || st["connected"] != HealthOK || st["jetstream"] != HealthOK {
+		t.Fatalf("缺少流与桶时应不就绪: %d %v", code, st)
+	}
+
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{Bucket: "settings"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.CreateObjectStore(ctx, jetstream.ObjectStoreConfig{Bucket: "files"}); err != nil {
+		t.Fatal(err)
+	}
+	code, r = probe(t, h, "/readyz")
+	if code != http.StatusOK || r.Status != HealthOK || len(r.Checks) != 6 {
+		t.Fatalf("应就绪: %d %+v", code, r)
+	}
+	for _, c := range r.Checks {
+		if c.Name == "connected" && c.Detail != nc.ConnectedUrlRedacted() {
+			t.Errorf("连接检查应包含服务器地址: %+v", c)
+		}
+	}
+
+	// 最大往返时间过小时不就绪
+	strict := NewHealth(nc, nil, HealthConfig{MaxRTT: 1})
+	if code, r := probe(t, strict, "/readyz"); code != http.StatusServiceUnavailable || checkStatus(r)["rtt"] != HealthFail {
+		t.Errorf("往返时间超限应不就绪: %d %+v", code, r)
+	}
+
+	s.Shutdown()
+	s.WaitForShutdown()
+	waitEvent(t, events, EventDisconnected)
+	code, r = probe(t, h, "/readyz")
+	st = checkStatus(r)
+	if code != http.StatusServiceUnavailable || st["connected"] != HealthFail || st["kv:settings"] != HealthSkipped {
+		t.Errorf("断开后应不就绪并跳过其余检查: %d %v", code, st)
+	}
+	if code, r := probe(t, h, "/livez"); code != http.StatusOK || r.Status != HealthOK {
+		t.Errorf("存活探针应返回 200: %d %+v", code, r)
+	}
+}
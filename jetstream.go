@@ -0,0 +1,199 @@
+package nats_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ErrJetStreamUnavailable 表示 JetStream 域不可达或账户未启用 JetStream
+var ErrJetStreamUnavailable = errors.New("nats: jetstream unavailable")
+
+// JetStreamConfig 描述 JetStream 的访问方式
+type JetStreamConfig struct {
+	Domain    string `json:"domain,omitempty"`     // JetStream 域
+	APIPrefix string `json:"api_prefix,omitempty"` // API 前缀，与 Domain 互斥
+
+	// Timeout API 请求的默认超时，用于旧版 API、启动校验与 JetStream.Context
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// PublishAsyncMaxPending 异步发布允许的最大未确认消息数
+	PublishAsyncMaxPending int `json:"publish_async_max_pending,omitempty"`
+	// PublishAsyncTimeout 异步发布等待确认的超时
+	PublishAsyncTimeout time.Duration `json:"publish_async_timeout,omitempty"`
+	// SkipValidation 为 true 时创建客户端后不检查 JetStream 是否可达
+	SkipValidation bool `json:"skip_validation,omitempty"`
+}
+
+// 默认的 JetStream API 超时
+const DefaultJetStreamTimeout = 5 * time.Second
+
+// JetStream 同时持有新版 jetstream.JetStream 与旧版 nats.JetStreamContext，
+// 两者使用同一份配置
+type JetStream struct {
+	jetstream.JetStream
+	Legacy nats.JetStreamContext
+	Config JetStreamConfig
+
+	metrics *Metrics
+}
+
+// NewJetStream 根据配置创建 JetStream 客户端，并通过账户信息确认域可达
+func NewJetStream(ctx context.Context, nc *nats.Conn, cfg JetStreamConfig) (*JetStream, error) {
+	if cfg.Domain != "" && cfg.APIPrefix != "" {
+		return nil, errors.New("nats: jetstream domain and api prefix are mutually exclusive")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultJetStreamTimeout
+	}
+
+	var jsOpts []jetstream.JetStreamOpt
+	legacyOpts := []nats.JSOpt{nats.MaxWait(cfg.Timeout)}
+	if cfg.PublishAsyncMaxPending > 0 {
+		jsOpts = append(jsOpts, jetstream.WithPublishAsyncMaxPending(cfg.PublishAsyncMaxPending))
+		legacyOpts = append(legacyOpts, nats.PublishAsyncMaxPending(cfg.PublishAsyncMaxPending))
+	}
+	if cfg.PublishAsyncTimeout > 0 {
+		jsOpts = append(jsOpts, jetstream.WithPublishAsyncTimeout(cfg.PublishAsyncTimeout))
+		legacyOpts = append(legacyOpts, nats.PublishAsyncTimeout(cfg.PublishAsyncTimeout))
+	}
+
+	var (
+		js  jetstream.JetStream
+		err error
+	)
+	switch {
+	case cfg.Domain != "":
+		js, err = jetstream.NewWithDomain(nc, cfg.Domain, jsOpts...)
+		legacyOpts = append(legacyOpts, nats.Domain(cfg.Domain))
+	case cfg.APIPrefix != "":
+		js, err = jetstream.NewWithAPIPrefix(nc, cfg.APIPrefix, jsOpts...)
+		legacyOpts = append(legacyOpts, nats.APIPrefix(cfg.APIPrefix))
+	default:
+		js, err = jetstream.New(nc, jsOpts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := nc.JetStream(legacyOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &JetStream{JetStream: js, Legacy: legacy, Config: cfg}
+	if !cfg.SkipValidation {
+		if err := j.Validate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// Validate 请求账户信息，确认 JetStream 域可达
+func (j *JetStream) Validate(ctx context.Context) error {
+	ctx, cancel := j.Context(ctx)
+	defer cancel()
+	if _, err := j.AccountInfo(ctx); err != nil {
+		target := "default domain"
+		switch {
+		case j.Config.Domain != "":
+			target = fmt.Sprintf("domain %q", j.Config.Domain)
+		case j.Config.APIPrefix != "":
+			target = fmt.Sprintf("api prefix %q", j.Config.APIPrefix)
+		}
+		return fmt.Errorf("%w (%s): %w", ErrJetStreamUnavailable, target, err)
+	}
+	return nil
+}
+
+// Context 返回带默认超时的 context，parent 已有截止时间时保持不变
+func (j *JetStream) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := parent.Deadline(); ok {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, j.Config.Timeout)
+}
+
+// Publish 同步发布并等待确认，启用指标时记录确认延迟
+func (j *JetStream) Publish(ctx context.Context, subject string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	return j.PublishMsg(ctx, &nats.Msg{Subject: subject, Data: data}, opts...)
+}
+
+// PublishMsg 同步发布消息并等待确认，启用指标时记录确认延迟
+func (j *JetStream) PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	start := time.Now()
+	ack, err := j.JetStream.PublishMsg(ctx, msg, opts...)
+	if j.metrics != nil {
+		j.metrics.observePublish(ack, time.Since(start), err)
+	}
+	return ack, err
+}
+
+// Consume 在 stream 上创建或更新消费者并开始消费，启用指标时统计该消费者的投递与确认次数
+func (j *JetStream) Consume(ctx context.Context, stream string, cfg jetstream.ConsumerConfig, handler jetstream.MessageHandler, opts ...jetstream.PullConsumeOpt) (jetstream.ConsumeContext, error) {
+	cctx, cancel := j.Context(ctx)
+	defer cancel()
+	cons, err := j.CreateOrUpdateConsumer(cctx, stream, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if j.metrics != nil {
+		handler = j.metrics.MessageHandler(stream, cons.CachedInfo().Name, handler)
+	}
+	return cons.Consume(handler, opts...)
+}
+
+// UnmarshalJSON 支持字符串形式的时间字段
+func (c *JetStreamConfig) UnmarshalJSON(data []byte) error {
+	type plain JetStreamConfig
+	aux := struct {
+		*plain
+		Timeout             jsonDuration `json:"timeout,omitempty"`
+		PublishAsyncTimeout jsonDuration `json:"publish_async_timeout,omitempty"`
+	}{
+		plain:               (*plain)(c),
+		Timeout:             jsonDuration(c.Timeout),
+		PublishAsyncTimeout: jsonDuration(c.PublishAsyncTimeout),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c.Timeout = time.Duration(aux.Timeout)
+	c.PublishAsyncTimeout = time.Duration(aux.PublishAsyncTimeout)
+	return nil
+}
+
+// ConnectJetStream 建立连接并按 Config.JetStream 创建 JetStream 客户端
+func (c *Config) ConnectJetStream(ctx context.Context) (*nats.Conn, *JetStream, error) {
+	nc, err := c.Connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	js, err := NewJetStream(ctx, nc, c.JetStream)
+	if err != nil {
+		nc.Close()
+		return nil, nil, err
+	}
+	js.metrics = c.Metrics
+	return nc, js, nil
+}
+
+// WithJetStream 设置 JetStream 访问配置
+func WithJetStream(cfg JetStreamConfig) Option {
+	return func(c *Config) error {
+		c.JetStream = cfg
+		return nil
+	}
+}
+
+// WithJetStreamDomain 设置 JetStream 域
+func WithJetStreamDomain(domain string) Option {
+	return func(c *Config) error {
+		c.JetStream.Domain = domain
+		return nil
+	}
+}
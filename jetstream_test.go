@@ -0,0 +1,137 @@
+package nats_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// TestNewJetStream 测试新旧两套 API 共用同一配置
+func TestNewJetStream(t *testing.T) {
+	s := natstest.RunServer(t)
+	nc, err := Connect(WithURL(s.ClientURL()), WithDialer(nil))
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	ctx := context.Background()
+	js, err := NewJetStream(ctx, nc, JetStreamConfig{Domain: "hub", PublishAsyncMaxPending: 16})
+	if err != nil {
+		t.Fatalf("创建 JetStream 客户端失败: %v", err)
+	}
+	if js.Config.Timeout != DefaultJetStreamTimeout {
+		t.Errorf("默认超时不匹配: %v", js.Config.Timeout)
+	}
+
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}}); err != nil {
+		t.Fatalf("新版 API 创建流失败: %v", err)
+	}
+	ack, err := js.Legacy.Publish("orders.new", []byte("1"))
+	if err != nil {
+		t.Fatalf("旧版 API 发布失败: %v", err)
+	}
+	if ack.Stream != "ORDERS" || ack.Domain != "hub" {
+		t.Errorf("发布确认不匹配: %+v", ack)
+	}
+	if _, err := js.Publish(ctx, "orders.new", []byte("2")); err != nil {
+		t.Fatalf("新版 API 发布失败: %v", err)
+	}
+}
+
+// TestNewJetStreamPublishAsyncTimeout 测试异步发布超时同时作用于新旧两套 API
+func TestNewJetStreamPublishAsyncTimeout(t *testing.T) {
+	s := natstest.RunServer(t)
+	nc, err := Connect(WithURL(s.ClientURL()), WithDialer(nil))
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	ctx := context.Background()
+	js, err := NewJetStream(ctx, nc, JetStreamConfig{PublishAsyncTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("创建 JetStream 客户端失败: %v", err)
+	}
+	// 有订阅者但从不确认，发布只能等到超时
+	if _, err := nc.SubscribeSync("silent.>"); err != nil {
+		t.Fatal(err)
+	}
+
+	paf, err := js.PublishAsync("silent.new", []byte("1"))
+	if err != nil {
+		t.Fatalf("新版 API 异步发布失败: %v", err)
+	}
+	legacy, err := js.Legacy.PublishAsync("silent.legacy", []byte("1"))
+	if err != nil {
+		t.Fatalf("旧版 API 异步发布失败: %v", err)
+	}
+	for _, c := range []struct {
+		name string
+		errs <-chan error
+		want error
+	}{
+		{"新版", paf.Err(), jetstream.ErrAsyncPublishTimeout},
+		{"旧版", legacy.Err(), nats.ErrAsyncPublishTimeout},
+	} {
+		select {
+		case err := <-c.errs:
+			if !errors.Is(err, c.want) {
+				t.Errorf("%s API 期望 %v，实际: %v", c.name, c.want, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("%s API 异步发布没有在超时后返回", c.name)
+		}
+	}
+}
+
+// TestNewJetStreamUnavailable 测试不可达的域在启动时返回明确的错误
+func TestNewJetStreamUnavailable(t *testing.T) {
+	s := natstest.RunServer(t)
+	nc, err := Connect(WithURL(s.ClientURL()), WithDialer(nil))
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	ctx := context.Background()
+	_, err = NewJetStream(ctx, nc, JetStreamConfig{Domain: "invalid-domain", Timeout: 500 * time.Millisecond})
+	if !errors.Is(err, ErrJetStreamUnavailable) {
+		t.Fatalf("期望 ErrJetStreamUnavailable，实际: %v", err)
+	}
+
+	_, err = NewJetStream(ctx, nc, JetStreamConfig{Domain: "invalid-domain", SkipValidation: true})
+	if err != nil {
+		t.Errorf("跳过校验时不应报错: %v", err)
+	}
+
+	_, err = NewJetStream(ctx, nc, JetStreamConfig{Domain: "hub", APIPrefix: "$JS.hub.API"})
+	if err == nil {
+		t.Error("同时设置域与 API 前缀时期望报错")
+	}
+
+	// 未启用 JetStream 的服务器
+	pc := natstest.Connect(t, natstest.RunServer(t, natstest.WithoutJetStream()))
+	if _, err := NewJetStream(ctx, pc, JetStreamConfig{Timeout: 500 * time.Millisecond}); !errors.Is(err, ErrJetStreamUnavailable) {
+		t.Errorf("期望 ErrJetStreamUnavailable，实际: %v", err)
+	}
+}
+
+// TestJetStreamConfigJSON 测试配置文件中的 JetStream 字段
+func TestJetStreamConfigJSON(t *testing.T) {
+	cfg := DefaultConfig()
+	data := `{"url":"nats://a:4222","jetstream":{"domain":"edge","timeout":"2s","publish_async_max_pending":256,"publish_async_timeout":"500ms"}}`
+	if err := json.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatalf("解析配置失败: %v", err)
+	}
+	want := JetStreamConfig{Domain: "edge", Timeout: 2 * time.Second, PublishAsyncMaxPending: 256, PublishAsyncTimeout: 500 * time.Millisecond}
+	if cfg.JetStream != want {
+		t.Errorf("JetStream 配置不匹配: got %+v, want %+v", cfg.JetStream, want)
+	}
+}
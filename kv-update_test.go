@@ -3,7 +3,6 @@ package nats_client
 import (
 	"context"
 	"fmt"
-	"log"
 	"testing"
 	"time"
 
@@ -12,11 +11,9 @@ import (
 
 func TestKvUpdate(t *testing.T) {
 	bucket := "my_bucket"
-	nc, err := NewNATSConnect()
-	if err != nil {
-		log.Fatalf("连接失败: %v", err)
-	}
+	nc := setupTestConnection(t)
 	defer nc.Close()
+	log := testLogger(t)
 
 	// nc.QueueSubscribe(subject, "queue", func(msg *nats.Msg) {
 	// 	log.Printf("Received a message on subject %s: %s", msg.Subject, string(msg.Data))
@@ -24,9 +21,9 @@ func TestKvUpdate(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	// js, err := jetstream.New(nc)
-	js, err := jetstream.NewWithDomain(nc, "hub")
+	js, err := testJetStream(nc)
 	if err != nil {
-		log.Fatalf("Error creating JetStream context: %v", err)
+		t.Fatalf("Error creating JetStream context: %v", err)
 	}
 
 	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
@@ -35,25 +32,25 @@ func TestKvUpdate(t *testing.T) {
 		History: 10,
 	})
 	if err != nil {
-		log.Printf("Error creating KeyValueBucket: %v", err)
+		log.Info("create bucket failed, opening existing", LogKeyBucket, bucket, LogKeyError, err)
 		if kv, err = js.KeyValue(ctx, bucket); err != nil {
-			log.Fatalf("Error open KeyValueBucket: %v", err)
+			t.Fatalf("Error open KeyValueBucket: %v", err)
 		}
 	}
-	log.Printf("Created KeyValue Bucket: %s", bucket)
+	log.Info("bucket ready", LogKeyBucket, bucket)
 	//删除 Bucket
 	defer js.DeleteKeyValue(ctx, bucket) // Consider calling cancel() before this to release resources.
 	revision, err := kv.Create(ctx, "key1", []byte("Hello, NATS! "))
 	if err != nil {
-		log.Printf("Error Creating KeyValue Entry: %v", err)
+		log.Info("create entry failed, reading existing", LogKeyBucket, bucket, LogKeyKey, "key1", LogKeyError, err)
 		if kve, err := kv.Get(ctx, "key1"); err == nil {
 			revision = kve.Revision()
 		} else {
-			log.Fatalf("Error getting KeyValue Entry: %v", err)
+			t.Fatalf("Error getting KeyValue Entry: %v", err)
 		}
-		log.Printf("Opened KeyValue Entry: %s, Revision: %d", "key1", revision)
+		log.Info("entry opened", LogKeyBucket, bucket, LogKeyKey, "key1", LogKeyRevision, revision)
 	} else {
-		log.Printf("Created KeyValue Entry: %s, Revision: %d", "key1", revision)
+		log.Info("entry created", LogKeyBucket, bucket, LogKeyKey, "key1", LogKeyRevision, revision)
 	}
 	// Publish messages to the subject
 	i := 0
@@ -63,9 +60,9 @@ func TestKvUpdate(t *testing.T) {
 		kvEntry, _ := kv.Get(ctx, "key1")
 
 		if kvEntry != nil {
-			log.Printf("Key: %s, Value: %s, Revision: %d", kvEntry.Key(), string(kvEntry.Value()), kvEntry.Revision())
+			log.Info("entry updated", LogKeyBucket, bucket, LogKeyKey, kvEntry.Key(), LogKeyRevision, kvEntry.Revision(), "value", string(kvEntry.Value()))
 		} else {
-			log.Printf("Key not found or expired")
+			log.Info("entry not found or expired", LogKeyBucket, bucket, LogKeyKey, "key1")
 		}
 		time.Sleep(time.Second)
 		i++
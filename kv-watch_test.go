@@ -2,26 +2,26 @@ package nats_client
 
 import (
 	"context"
-	"log"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go/jetstream"
 )
 
 func TestKvWatch(t *testing.T) {
 	bucket := "my_bucket"
-	nc, err := NewNATSConnect()
-	if err != nil {
-		log.Fatalf("连接失败: %v", err)
-	}
+	nc := setupTestConnection(t)
 	defer nc.Close()
+	log := testLogger(t)
 
 	// nc.QueueSubscribe(subject, "queue", func(msg *nats.Msg) {
 	// 	log.Printf("Received a message on subject %s: %s", msg.Subject, string(msg.Data))
 	// })
 
-	ctx := context.WithoutCancel(context.Background())
-	js, err := jetstream.NewWithDomain(nc, "hub")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	js, err := testJetStream(nc)
 
 	// js.Stream("EVENTS", nats.StreamConfig{
 	// 	Name:     "EVENTS",
@@ -29,40 +29,57 @@ func TestKvWatch(t *testing.T) {
 	//   Storage:  nats.FileStorage, // 持久化到��盘
 	// })
 	if err != nil {
-		log.Fatalf("Error creating JetStream context: %v", err)
+		t.Fatalf("Error creating JetStream context: %v", err)
 	}
 
 	kv, err := js.KeyValue(ctx, bucket)
+	if errors.Is(err, jetstream.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(ctx, jetstream.KeyValueConfig{Bucket: bucket})
+	}
 	if err != nil {
-		log.Fatalf("Error Open KeyValue: %v", err)
+		t.Fatalf("Error Open KeyValue: %v", err)
 	}
 
 	// 启动watcher，监控所有key的变化
 	watcher, err := kv.WatchAll(ctx)
 	if err != nil {
-		log.Fatalf("启动watcher失败: %v", err)
+		t.Fatalf("启动watcher失败: %v", err)
 	}
 	defer watcher.Stop()
 
-	log.Println("正在监控 KeyValue 变化...")
+	log.Info("watching bucket", LogKeyBucket, bucket)
+
+	// 写入 foo 的两次修改，最后一次为 baz
+	go func() {
+		for _, v := range []string{"bar", "baz"} {
+			if _, err := kv.PutString(ctx, "foo", v); err != nil {
+				t.Errorf("写入 foo 失败: %v", err)
+			}
+		}
+	}()
 
 	// 监控变化并打印
+	seen := false
 	for entry := range watcher.Updates() {
 		if entry == nil {
 			continue // watch 启动时会有 nil
 		}
 		switch entry.Operation() {
 		case jetstream.KeyValuePut:
-			log.Printf("Key [%s] 被写入/更新, Value: %s, Revision: %d\n", entry.Key(), entry.Value(), entry.Revision())
+			log.Info("entry put", LogKeyBucket, bucket, LogKeyKey, entry.Key(), LogKeyRevision, entry.Revision(), "value", string(entry.Value()))
 		case jetstream.KeyValueDelete:
-			log.Printf("Key [%s] 被删除, Revision: %d\n", entry.Key(), entry.Revision())
+			log.Info("entry deleted", LogKeyBucket, bucket, LogKeyKey, entry.Key(), LogKeyRevision, entry.Revision())
 		case jetstream.KeyValuePurge:
-			log.Printf("Key [%s] 被彻底清除, Revision: %d\n", entry.Key(), entry.Revision())
+			log.Info("entry purged", LogKeyBucket, bucket, LogKeyKey, entry.Key(), LogKeyRevision, entry.Revision())
 		}
 		if entry.Key() == "foo" && entry.Value() != nil && string(entry.Value()) == "baz" {
 			// 监控到最后一次修改后退出
+			seen = true
 			break
 		}
 	}
-	log.Println("监控结束。")
+	if !seen {
+		t.Error("未监控到 foo 的最后一次修改")
+	}
+	log.Info("watch finished", LogKeyBucket, bucket)
 }
@@ -0,0 +1,88 @@
+package nats_client
+
+import (
+	"context"
+	"log/slog"
+)
+
+// 日志中统一使用的键，调用方记录与 NATS 相关的日志时也应使用这些键
+const (
+	LogKeyConn     = "conn"     // 连接名称
+	LogKeyCluster  = "cluster"  // Failover 集群名称
+	LogKeyServer   = "server"   // 服务器地址，已隐藏密码
+	LogKeySubject  = "subject"  // 主题
+	LogKeyStream   = "stream"   // 流名称
+	LogKeyConsumer = "consumer" // 消费者名称
+	LogKeySeq      = "seq"      // 流序号
+	LogKeyBucket   = "bucket"   // KV 或对象存储桶
+	LogKeyKey      = "key"      // KV 键
+	LogKeyRevision = "revision" // KV 版本
+	LogKeyObject   = "object"   // 对象名称
+	LogKeyPath     = "path"     // 本地文件路径
+	LogKeyTransfer = "transfer" // 传输名称
+	LogKeyError    = "error"    // 错误
+)
+
+// logger 返回 l，为 nil 时返回 slog.Default()
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+// errAttr 以 LogKeyError 记录错误
+func errAttr(err error) slog.Attr {
+	return slog.Any(LogKeyError, err)
+}
+
+// level 事件的日志级别：异步错误为 Error，断开与失败类事件为 Warn，其余为 Info
+func (e Event) level() slog.Level {
+	switch e.Type {
+	case EventAsyncError:
+		return slog.LevelError
+	case EventDisconnected, EventReconnectError, EventProxyError, EventConnectRetry,
+		EventLameDuck, EventSlowConsumer, EventFailover:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// LogAttrs 返回事件的结构化字段，零值字段省略
+func (e Event) LogAttrs() []slog.Attr {
+	attrs := []slog.Attr{slog.String("event", string(e.Type))}
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, slog.String(key, value))
+		}
+	}
+	add(LogKeyConn, e.Name)
+	add(LogKeyCluster, e.Cluster)
+	add(LogKeyServer, e.Server)
+	add(LogKeySubject, e.Subject)
+	add(LogKeyStream, e.Stream)
+	add(LogKeyConsumer, e.Consumer)
+	if len(e.Servers) > 0 {
+		attrs = append(attrs, slog.Any("servers", e.Servers))
+	}
+	if e.Attempt > 0 {
+		attrs = append(attrs, slog.Int("attempt", e.Attempt), slog.Duration("delay", e.Delay))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, errAttr(e.Err))
+	}
+	return attrs
+}
+
+// logEvent 将事件写入 l
+func logEvent(l *slog.Logger, e Event) {
+	l.LogAttrs(context.Background(), e.level(), "nats event", e.LogAttrs()...)
+}
+
+// WithLogger 设置连接事件、证书与凭证轮换等日志使用的 slog.Logger，默认 slog.Default()
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Config) error {
+		c.Logger = l
+		return nil
+	}
+}
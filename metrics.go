@@ -0,0 +1,504 @@
+package nats_client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DefaultLatencyBuckets 发布确认延迟直方图的默认桶 (秒)
+var DefaultLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Metrics 收集客户端指标并以 Prometheus 文本格式输出，实现 http.Handler。
+// 通过 WithMetrics 接入后，Connect 建立的连接、JetStream 同步发布、
+// JetStream.Consume 创建的消费者以及 Failover 自动登记，已关闭的连接在下次抓取时移除
+type Metrics struct {
+	// RTTTimeout 抓取时测量每个连接往返时间的超时，默认 2s
+	RTTTimeout time.Duration
+
+	mu        sync.Mutex
+	conns     map[*nats.Conn]string
+	subs      map[*nats.Subscription]struct{}
+	events    map[EventType]uint64
+	publish   map[string]*histogram // 按流
+	pubErrors uint64
+	consumers map[consumerKey]*consumerCounters
+	failovers map[*Failover]struct{}
+}
+
+type consumerKey struct {
+	stream, consumer string
+}
+
+// consumerCounters 单个消费者的消息计数
+type consumerCounters struct {
+	delivered, redelivered, acked, naked, termed atomic.Uint64
+}
+
+// NewMetrics 创建指标集合
+func NewMetrics() *Metrics {
+	return &Metrics{
+		RTTTimeout: 2 * time.Second,
+		conns:      make(map[*nats.Conn]string),
+		subs:       make(map[*nats.Subscription]struct{}),
+		events:     make(map[EventType]uint64),
+		publish:    make(map[string]*histogram),
+		consumers:  make(map[consumerKey]*consumerCounters),
+		failovers:  make(map[*Failover]struct{}),
+	}
+}
+
+// Register 登记连接，指标中以连接名称区分，与现有连接重名时追加最小的可用序号
+func (m *Metrics) Register(nc *nats.Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.conns[nc]; ok {
+		return
+	}
+	base := nc.Opts.Name
+	if base == "" {
+		base = "unnamed"
+	}
+	used := make(map[string]bool, len(m.conns))
+	for c, name := range m.conns {
+		if !c.IsClosed() {
+			used[name] = true
+		}
+	}
+	name := base
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	m.conns[nc] = name
+}
+
+// Track 登记订阅，输出其待处理消息数与字节数，退订后移除
+func (m *Metrics) Track(sub *nats.Subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs[sub] = struct{}{}
+}
+
+// observe 统计事件数量，作为事件处理器接入
+func (m *Metrics) observe(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[e.Type]++
+}
+
+// observePublish 记录一次同步发布的确认延迟
+func (m *Metrics) observePublish(ack *jetstream.PubAck, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.pubErrors++
+		return
+	}
+	h := m.publish[ack.Stream]
+	if h == nil {
+		h = newHistogram(DefaultLatencyBuckets)
+		m.publish[ack.Stream] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// consumer 返回消费者的计数器，不存在时创建
+func (m *Metrics) consumer(stream, name string) *consumerCounters {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := consumerKey{stream, name}
+	c := m.consumers[k]
+	if c == nil {
+		c = &consumerCounters{}
+		m.consumers[k] = c
+	}
+	return c
+}
+
+// MessageHandler 包装消费者的处理函数，统计投递、重新投递以及 Ack/Nak/Term 次数
+func (m *Metrics) MessageHandler(stream, consumer string, h jetstream.MessageHandler) jetstream.MessageHandler {
+	c := m.consumer(stream, consumer)
+	return func(msg jetstream.Msg) {
+		c.delivered.Add(1)
+		if md, err := msg.Metadata(); err == nil && md.NumDelivered > 1 {
+			c.redelivered.Add(1)
+		}
+		h(&countedMsg{Msg: msg, c: c})
+	}
+}
+
+// countedMsg 统计确认结果的 jetstream.Msg
+type countedMsg struct {
+	jetstream.Msg
+	c *consumerCounters
+}
+
+func (m *countedMsg) Ack() error {
+	return m.count(m.Msg.Ack(), &m.c.acked)
+}
+
+func (m *countedMsg) DoubleAck(ctx context.Context) error {
+	return m.count(m.Msg.DoubleAck(ctx), &m.c.acked)
+}
+
+func (m *countedMsg) Nak() error {
+	return m.count(m.Msg.Nak(), &m.c.naked)
+}
+
+func (m *countedMsg) NakWithDelay(delay time.Duration) error {
+	return m.count(m.Msg.NakWithDelay(delay), &m.c.naked)
+}
+
+func (m *countedMsg) Term() error {
+	return m.count(m.Msg.Term(), &m.c.termed)
+}
+
+func (m *countedMsg) TermWithReason(reason string) error {
+	return m.count(m.Msg.TermWithReason(reason), &m.c.termed)
+}
+
+func (m *countedMsg) count(err error, n *atomic.Uint64) error {
+	if err == nil {
+		n.Add(1)
+	}
+	return err
+}
+
+// registerFailover 登记主备切换管理器，关闭后移除
+func (m *Metrics) registerFailover(f *Failover) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failovers[f] = struct{}{}
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出全部指标
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// connSample 一次抓取中单个连接的数据
+type connSample struct {
+	name      string
+	stats     nats.Statistics
+	connected bool
+	rtt       time.Duration
+}
+
+// WriteTo 将全部指标以 Prometheus 文本格式写入 w
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	conns := m.sampleConns()
+	failovers := m.sampleFailovers()
+	ew := &expositionWriter{w: w}
+
+	ew.family("nats_connected", "gauge", "连接当前是否可用")
+	for _, c := range conns {
+		ew.sample("nats_connected", labels("conn", c.name), boolValue(c.connected))
+	}
+	for _, f := range []struct {
+		name, help string
+		value      func(nats.Statistics) uint64
+	}{
+		{"nats_in_msgs_total", "收到的消息数", func(s nats.Statistics) uint64 { return s.InMsgs }},
+		{"nats_out_msgs_total", "发出的消息数", func(s nats.Statistics) uint64 { return s.OutMsgs }},
+		{"nats_in_bytes_total", "收到的字节数", func(s nats.Statistics) uint64 { return s.InBytes }},
+		{"nats_out_bytes_total", "发出的字节数", func(s nats.Statistics) uint64 { return s.OutBytes }},
+		{"nats_reconnects_total", "重连次数", func(s nats.Statistics) uint64 { return s.Reconnects }},
+	} {
+		ew.family(f.name, "counter", f.help)
+		for _, c := range conns {
+			ew.sample(f.name, labels("conn", c.name), float64(f.value(c.stats)))
+		}
+	}
+	ew.family("nats_rtt_seconds", "gauge", "到服务器的往返时间，连接不可用时不输出")
+	for _, c := range conns {
+		if c.rtt > 0 {
+			ew.sample("nats_rtt_seconds", labels("conn", c.name), c.rtt.Seconds())
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 同一主题与队列组的多个订阅合并输出
+	type subKey struct{ subject, queue string }
+	pending := make(map[subKey]*[2]int)
+	for sub := range m.subs {
+		msgs, bytes, err := sub.Pending()
+		if err != nil {
+			// 已退订或连接已关闭
+			delete(m.subs, sub)
+			continue
+		}
+		k := subKey{sub.Subject, sub.Queue}
+		if pending[k] == nil {
+			pending[k] = new([2]int)
+		}
+		pending[k][0] += msgs
+		pending[k][1] += bytes
+	}
+	subKeys := make([]subKey, 0, len(pending))
+	for k := range pending {
+		subKeys = append(subKeys, k)
+	}
+	sort.Slice(subKeys, func(i, j int) bool {
+		if subKeys[i].subject != subKeys[j].subject {
+			return subKeys[i].subject < subKeys[j].subject
+		}
+		return subKeys[i].queue < subKeys[j].queue
+	})
+	for i, f := range []struct{ name, help string }{
+		{"nats_subscription_pending_msgs", "订阅缓冲区中待处理的消息数"},
+		{"nats_subscription_pending_bytes", "订阅缓冲区中待处理的字节数"},
+	} {
+		ew.family(f.name, "gauge", f.help)
+		for _, k := range subKeys {
+			ew.sample(f.name, labels("subject", k.subject, "queue", k.queue), float64(pending[k][i]))
+		}
+	}
+
+	ew.family("nats_events_total", "counter", "连接生命周期事件数")
+	for _, t := range sortedKeys(m.events) {
+		ew.sample("nats_events_total", labels("type", string(t)), float64(m.events[t]))
+	}
+
+	ew.family("nats_jetstream_publish_ack_seconds", "histogram", "JetStream 同步发布等待确认的延迟")
+	for _, stream := range sortedKeys(m.publish) {
+		m.publish[stream].write(ew, "nats_jetstream_publish_ack_seconds", "stream", stream)
+	}
+	ew.family("nats_jetstream_publish_errors_total", "counter", "JetStream 同步发布失败次数")
+	ew.sample("nats_jetstream_publish_errors_total", "", float64(m.pubErrors))
+
+	keys := make([]consumerKey, 0, len(m.consumers))
+	for k := range m.consumers {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].stream != keys[j].stream {
+			return keys[i].stream < keys[j].stream
+		}
+		return keys[i].consumer < keys[j].consumer
+	})
+	for _, f := range []struct {
+		name, help string
+		value      func(*consumerCounters) uint64
+	}{
+		{"nats_consumer_delivered_total", "消费者收到的消息数", func(c *consumerCounters) uint64 { return c.delivered.Load() }},
+		{"nats_consumer_redelivered_total", "消费者收到的重新投递消息数", func(c *consumerCounters) uint64 { return c.redelivered.Load() }},
+		{"nats_consumer_acked_total", "消费者确认的消息数", func(c *consumerCounters) uint64 { return c.acked.Load() }},
+		{"nats_consumer_naked_total", "消费者否认 (Nak) 的消息数", func(c *consumerCounters) uint64 { return c.naked.Load() }},
+		{"nats_consumer_termed_total", "消费者终止 (Term) 的消息数", func(c *consumerCounters) uint64 { return c.termed.Load() }},
+	} {
+		ew.family(f.name, "counter", f.help)
+		for _, k := range keys {
+			ew.sample(f.name, labels("stream", k.stream, "consumer", k.consumer), float64(f.value(m.consumers[k])))
+		}
+	}
+
+	if len(failovers) > 0 {
+		// 以主备集群名称标识 Failover，切换时序列不变，只有值变化
+		ew.family("nats_failover_active", "gauge", "集群是否为当前使用的集群")
+		for _, fs := range failovers {
+			for _, cluster := range []string{fs.primary, fs.secondary} {
+				ew.sample("nats_failover_active", labels("primary", fs.primary, "secondary", fs.secondary, "cluster", cluster), boolValue(fs.Active == cluster))
+			}
+		}
+		ew.family("nats_failover_switches_total", "counter", "主备集群切换次数")
+		for _, fs := range failovers {
+			ew.sample("nats_failover_switches_total", labels("primary", fs.primary, "secondary", fs.secondary), float64(fs.Switches))
+		}
+	}
+	return ew.n, ew.err
+}
+
+// sampleConns 读取连接统计并并行测量往返时间，同时移除已关闭的连接
+func (m *Metrics) sampleConns() []connSample {
+	m.mu.Lock()
+	samples := make([]connSample, 0, len(m.conns))
+	var ncs []*nats.Conn
+	for nc, name := range m.conns {
+		if nc.IsClosed() {
+			delete(m.conns, nc)
+			continue
+		}
+		samples = append(samples, connSample{name: name})
+		ncs = append(ncs, nc)
+	}
+	timeout := m.RTTTimeout
+	m.mu.Unlock()
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	var wg sync.WaitGroup
+	for i, nc := range ncs {
+		samples[i].stats = nc.Stats()
+		samples[i].connected = nc.IsConnected()
+		if !samples[i].connected {
+			continue
+		}
+		wg.Add(1)
+		go func(s *connSample, nc *nats.Conn) {
+			defer wg.Done()
+			start := time.Now()
+			if err := nc.FlushTimeout(timeout); err == nil {
+				s.rtt = time.Since(start)
+			}
+		}(&samples[i], nc)
+	}
+	wg.Wait()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].name < samples[j].name })
+	return samples
+}
+
+// failoverSample 一个 Failover 的主备集群名称与切换状态
+type failoverSample struct {
+	primary, secondary string
+	FailoverStats
+}
+
+// sampleFailovers 读取切换状态，移除已关闭的 Failover。
+// Failover 持有自身的锁时会登记订阅与消费者，这里不能在持有 m.mu 时调用它
+func (m *Metrics) sampleFailovers() []failoverSample {
+	m.mu.Lock()
+	fs := make([]*Failover, 0, len(m.failovers))
+	for f := range m.failovers {
+		fs = append(fs, f)
+	}
+	m.mu.Unlock()
+
+	var samples []failoverSample
+	for _, f := range fs {
+		if f.isClosed() {
+			m.mu.Lock()
+			delete(m.failovers, f)
+			m.mu.Unlock()
+			continue
+		}
+		samples = append(samples, failoverSample{primary: f.cfg.Primary.Name, secondary: f.cfg.Secondary.Name, FailoverStats: f.Stats()})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].primary != samples[j].primary {
+			return samples[i].primary < samples[j].primary
+		}
+		return samples[i].secondary < samples[j].secondary
+	})
+	return samples
+}
+
+// histogram 累积直方图
+type histogram struct {
+	bounds []float64
+	counts []uint64 // 与 bounds 对应，不含 +Inf
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(ew *expositionWriter, name string, kv ...string) {
+	for i, b := range h.bounds {
+		ew.sample(name+"_bucket", labels(append(kv, "le", formatFloat(b))...), float64(h.counts[i]))
+	}
+	ew.sample(name+"_bucket", labels(append(kv, "le", "+Inf")...), float64(h.count))
+	ew.sample(name+"_sum", labels(kv...), h.sum)
+	ew.sample(name+"_count", labels(kv...), float64(h.count))
+}
+
+// expositionWriter 写入 Prometheus 文本格式，记录第一个错误
+type expositionWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (ew *expositionWriter) printf(format string, args ...any) {
+	if ew.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(ew.w, format, args...)
+	ew.n += int64(n)
+	ew.err = err
+}
+
+func (ew *expositionWriter) family(name, typ, help string) {
+	ew.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (ew *expositionWriter) sample(name, labels string, v float64) {
+	ew.printf("%s%s %s\n", name, labels, formatFloat(v))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels 将键值对格式化为 {k="v",...}，值为空的标签输出为 k=""，同一指标的标签集合保持一致
+func labels(kv ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if b.Len() == 0 {
+			b.WriteByte('{')
+		} else {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, kv[i], labelEscaper.Replace(kv[i+1]))
+	}
+	if b.Len() > 0 {
+		b.WriteByte('}')
+	}
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// WithMetrics 将连接、事件、JetStream 发布与消费者指标记录到 m
+func WithMetrics(m *Metrics) Option {
+	return func(c *Config) error {
+		c.Metrics = m
+		c.EventHandlers = append(c.EventHandlers, m.observe)
+		return nil
+	}
+}
@@ -0,0 +1,166 @@
+package nats_client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// scrape 请求指标并返回文本
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type 不匹配: %s", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func expectMetrics(t *testing.T, text string, lines ...string) {
+	t.Helper()
+	for _, l := range lines {
+		if !strings.Contains(text, l+"\n") {
+			t.Errorf("缺少指标 %q", l)
+		}
+	}
+}
+
+// TestMetrics 测试连接、订阅、发布确认与消费者指标
+func TestMetrics(t *testing.T) {
+	s := natstest.RunServer(t)
+	m := NewMetrics()
+	cfg, err := NewConfig(WithURL(s.ClientURL()), WithName("metrics"), WithDialer(nil), WithJetStreamDomain(natstest.Domain), WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	nc, js, err := cfg.ConnectJetStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	sub, err := nc.SubscribeSync("metrics.core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Track(sub)
+	for i := 0; i < 3; i++ {
+		nc.Publish("metrics.core", []byte("x"))
+	}
+	nc.Flush()
+
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "METRICS", Subjects: []string{"metrics.js"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := js.Publish(ctx, "metrics.js", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := js.Publish(ctx, "metrics.none", nil); err == nil {
+		t.Fatal("没有流的主题期望发布失败")
+	}
+
+	// 第一条消息先 Nak，重新投递后确认
+	done := make(chan struct{})
+	var naked bool
+	cc, err := js.Consume(ctx, "METRICS", jetstream.ConsumerConfig{Durable: "worker"}, func(msg jetstream.Msg) {
+		if !naked {
+			naked = true
+			msg.Nak()
+			return
+		}
+		msg.Ack()
+		if md, _ := msg.Metadata(); md.Sequence.Stream == 2 {
+			close(done)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待消费超时")
+	}
+
+	text := scrape(t, m)
+	expectMetrics(t, text,
+		`# TYPE nats_in_msgs_total counter`,
+		`nats_connected{conn="metrics"} 1`,
+		`nats_reconnects_total{conn="metrics"} 0`,
+		`nats_subscription_pending_msgs{subject="metrics.core",queue=""} 3`,
+		`nats_subscription_pending_bytes{subject="metrics.core",queue=""} 3`,
+		`nats_events_total{type="connected"} 1`,
+		`nats_jetstream_publish_ack_seconds_bucket{stream="METRICS",le="+Inf"} 2`,
+		`nats_jetstream_publish_ack_seconds_count{stream="METRICS"} 2`,
+		`nats_jetstream_publish_errors_total 1`,
+		`nats_consumer_delivered_total{stream="METRICS",consumer="worker"} 3`,
+		`nats_consumer_redelivered_total{stream="METRICS",consumer="worker"} 1`,
+		`nats_consumer_acked_total{stream="METRICS",consumer="worker"} 2`,
+		`nats_consumer_naked_total{stream="METRICS",consumer="worker"} 1`,
+	)
+	if !strings.Contains(text, `nats_rtt_seconds{conn="metrics"} `) {
+		t.Error("缺少往返时间")
+	}
+
+	// 关闭的连接与退订的订阅在下次抓取时移除
+	sub.Unsubscribe()
+	nc.Close()
+	text = scrape(t, m)
+	if strings.Contains(text, `conn="metrics"`) || strings.Contains(text, `subject="metrics.core"`) {
+		t.Errorf("关闭的连接仍然输出:\n%s", text)
+	}
+}
+
+// TestMetricsFailover 测试主备切换指标与重名连接
+func TestMetricsFailover(t *testing.T) {
+	secondary := natstest.RunServer(t)
+	m := NewMetrics()
+	f, err := NewFailover(FailoverConfig{
+		Primary:   FailoverCluster{Name: "east", Options: []Option{WithURL(fmt.Sprintf("nats://127.0.0.1:%d", natstest.FreePort(t)))}},
+		Secondary: FailoverCluster{Name: "west", Options: []Option{WithURL(secondary.ClientURL())}},
+	}, WithDialer(nil), WithName("app"), WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Subscribe("fo.metrics", func(*nats.Msg) {}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := Connect(WithURL(secondary.ClientURL()), WithDialer(nil), WithName("app"), WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	expectMetrics(t, scrape(t, m),
+		`nats_connected{conn="app"} 1`,
+		`nats_connected{conn="app-2"} 1`,
+		`nats_subscription_pending_msgs{subject="fo.metrics",queue=""} 0`,
+		`nats_failover_active{primary="east",secondary="west",cluster="east"} 0`,
+		`nats_failover_active{primary="east",secondary="west",cluster="west"} 1`,
+		`nats_failover_switches_total{primary="east",secondary="west"} 0`,
+	)
+	f.Close()
+	if text := scrape(t, m); strings.Contains(text, "nats_failover_active") {
+		t.Error("关闭的 Failover 仍然输出")
+	}
+}
@@ -1,25 +1,17 @@
 package nats_client
 
 import (
-	"context"
-	"fmt"
-	"log"
 	"testing"
+	"time"
 
 	services "github.com/nats-io/nats.go/micro"
 	//nolint
 )
 
 func TestMicroSV(t *testing.T) {
-	ctx := context.Background()
-
-	nc, err := NewNATSConnect()
-	if err != nil {
-		log.Fatalf("连接失败: %v", err)
-	}
+	nc := setupTestConnection(t)
 	defer nc.Close()
-
-	fmt.Println("Starting echo service")
+	log := testLogger(t)
 
 	sv, err := services.AddService(nc, services.Config{
 		Name:        "UserSV",
@@ -36,10 +28,13 @@ func TestMicroSV(t *testing.T) {
 		// 	}),
 		// },
 	})
+	if err != nil {
+		t.Fatalf("创建服务失败: %v", err)
+	}
+	defer sv.Stop()
 	sv.AddEndpoint("login",
 		services.HandlerFunc(func(req services.Request) {
-			log.Printf("Received request: %s\n", string(req.Subject()))
-			log.Printf("Received request: %s\n", string(req.Data()))
+			log.Info("request received", LogKeySubject, req.Subject(), "data", string(req.Data()))
 			//req.Respond(req.Data())
 			req.Error("400", "Bad Request", []byte("Invalid login credentials"))
 		}),
@@ -50,8 +45,7 @@ func TestMicroSV(t *testing.T) {
 		}),
 	)
 	sv.AddEndpoint("logout", services.HandlerFunc(func(req services.Request) {
-		log.Printf("Received request: %s\n", string(req.Subject()))
-		log.Printf("Received request: %s\n", string(req.Data()))
+		log.Info("request received", LogKeySubject, req.Subject(), "data", string(req.Data()))
 		req.Respond(req.Data())
 	}), services.WithEndpointSubject("User.logout"),
 		services.WithEndpointMetadata(map[string]string{
@@ -59,8 +53,7 @@ func TestMicroSV(t *testing.T) {
 			"MCP":         "User management",
 		}))
 	sv.AddEndpoint("check", services.HandlerFunc(func(req services.Request) {
-		log.Printf("Received request: %s\n", string(req.Subject()))
-		log.Printf("Received request: %s\n", string(req.Data()))
+		log.Info("request received", LogKeySubject, req.Subject(), "data", string(req.Data()))
 		req.Respond(req.Data())
 	}), services.WithEndpointSubject("User.check"),
 		services.WithEndpointMetadata(map[string]string{
@@ -68,17 +61,34 @@ func TestMicroSV(t *testing.T) {
 			"MCP":         "User management",
 		}))
 	sv.AddEndpoint("create", services.HandlerFunc(func(req services.Request) {
-		log.Printf("Received request: %s\n", string(req.Subject()))
-		log.Printf("Received request: %s\n", string(req.Data()))
+		log.Info("request received", LogKeySubject, req.Subject(), "data", string(req.Data()))
 		req.Respond(req.Data())
 	}), services.WithEndpointSubject("User.create"),
 		services.WithEndpointMetadata(map[string]string{
 			"description": "创建",
 			"MCP":         "User management",
 		}))
+
+	// 调用各个端点，login 返回错误，其余端点原样返回请求数据
+	for _, subject := range []string{"User.logout", "User.check", "User.create"} {
+		resp, err := nc.Request(subject, []byte("alice"), 2*time.Second)
+		if err != nil {
+			t.Fatalf("请求 %s 失败: %v", subject, err)
+		}
+		if string(resp.Data) != "alice" {
+			t.Errorf("%s 响应不匹配: %q", subject, resp.Data)
+		}
+	}
+	resp, err := nc.Request("User.login", []byte("alice"), 2*time.Second)
 	if err != nil {
-		panic(err)
+		t.Fatalf("请求 User.login 失败: %v", err)
+	}
+	if code := resp.Header.Get(services.ErrorCodeHeader); code != "400" {
+		t.Errorf("User.login 错误码不匹配: %q", code)
 	}
 
-	<-ctx.Done()
+	info := sv.Info()
+	if info.Name != "UserSV" || len(info.Endpoints) != 4 {
+		t.Errorf("服务信息不匹配: %+v", info)
+	}
 }
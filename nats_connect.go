@@ -1,20 +1,18 @@
 package nats_client
 
 import (
-	"log"
 	"os"
 
 	"github.com/nats-io/nats.go"
-	"golang.org/x/net/proxy"
 )
 
+// NewNATSConnect 使用环境变量 (NATS_URL、NATS_CREDS 等) 建立连接。
+// 设置了 NATS_CONTEXT 时先读取该 nats CLI 上下文，环境变量中显式设置的值优先。
+// 需要更多控制时使用 Connect 与 Option。
 func NewNATSConnect() (*nats.Conn, error) {
-	opts := []nats.Option{nats.Name("nats-client")}
-	natsUrl := os.Getenv("NATS_URL")
-	opts = append(opts, nats.SetCustomDialer(proxy.FromEnvironment()))
-	opts = append(opts, nats.ConnectHandler(func(c *nats.Conn) {
-		log.Printf(": [NATS] Connected\n")
-	}))
-	nc, err := nats.Connect(natsUrl, opts...)
-	return nc, err
+	var opts []Option
+	if os.Getenv("NATS_CONTEXT") != "" {
+		opts = append(opts, WithNATSContext(""))
+	}
+	return Connect(append(opts, WithEnv())...)
 }
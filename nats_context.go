@@ -0,0 +1,186 @@
+package nats_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSContext 对应 nats CLI 保存在 ~/.config/nats/context/<name>.json 中的连接配置
+type NATSContext struct {
+	Name               string `json:"-"`
+	Description        string `json:"description"`
+	URL                string `json:"url"`
+	SocksProxy         string `json:"socks_proxy"`
+	Token              string `json:"token"`
+	User               string `json:"user"`
+	Password           string `json:"password"`
+	Creds              string `json:"creds"`
+	NKey               string `json:"nkey"`
+	UserJWT            string `json:"user_jwt"`
+	Cert               string `json:"cert"`
+	Key                string `json:"key"`
+	CA                 string `json:"ca"`
+	NSCLookup          string `json:"nsc"`
+	JetStreamDomain    string `json:"jetstream_domain"`
+	JetStreamAPIPrefix string `json:"jetstream_api_prefix"`
+	InboxPrefix        string `json:"inbox_prefix"`
+	TLSFirst           bool   `json:"tls_first"`
+}
+
+// NATSContextDir 返回 nats CLI 的上下文目录，遵循 XDG_CONFIG_HOME
+func NATSContextDir() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "nats", "context"), nil
+}
+
+// SelectedNATSContext 返回当前选中的上下文名称：
+// 优先使用 NATS_CONTEXT，其次是 nats CLI 记录在 context.txt 中的选择
+func SelectedNATSContext() string {
+	if name := os.Getenv("NATS_CONTEXT"); name != "" {
+		return name
+	}
+	dir, err := NATSContextDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(dir), "context.txt"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// LoadNATSContext 读取指定名称的上下文，name 为空时使用 SelectedNATSContext。
+// name 也可以是 JSON 文件的路径。
+func LoadNATSContext(name string) (*NATSContext, error) {
+	if name == "" {
+		name = SelectedNATSContext()
+	}
+	if name == "" {
+		return nil, errors.New("nats: no context selected")
+	}
+
+	path := name
+	if !strings.HasSuffix(name, ".json") {
+		if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("nats: invalid context name %q", name)
+		}
+		dir, err := NATSContextDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, name+".json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("nats: load context %q: %w", name, err)
+	}
+	nctx := &NATSContext{}
+	if err := json.Unmarshal(data, nctx); err != nil {
+		return nil, fmt.Errorf("nats: parse context %q: %w", name, err)
+	}
+	nctx.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	return nctx, nil
+}
+
+// Apply 将上下文写入 Config，上下文中为空的字段不会覆盖原值
+func (x *NATSContext) Apply(c *Config) error {
+	if x.NSCLookup != "" {
+		return fmt.Errorf("nats: context %q: nsc lookup is not supported", x.Name)
+	}
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&c.URL, x.URL)
+	set(&c.Token, x.Token)
+	set(&c.User, x.User)
+	set(&c.Password, x.Password)
+	set(&c.CredsFile, expandPath(x.Creds))
+	set(&c.CAFile, expandPath(x.CA))
+	set(&c.CertFile, expandPath(x.Cert))
+	set(&c.KeyFile, expandPath(x.Key))
+	set(&c.InboxPrefix, x.InboxPrefix)
+	set(&c.JetStream.Domain, x.JetStreamDomain)
+	set(&c.JetStream.APIPrefix, x.JetStreamAPIPrefix)
+	if x.TLSFirst {
+		c.TLSHandshakeFirst = true
+	}
+
+	// user_jwt 与 nkey 同时出现时，nkey 是 JWT 对应的用户种子
+	if x.UserJWT != "" {
+		if x.NKey == "" {
+			return fmt.Errorf("nats: context %q: user_jwt requires nkey", x.Name)
+		}
+		seed, err := os.ReadFile(expandPath(x.NKey))
+		if err != nil {
+			return fmt.Errorf("nats: context %q: read nkey: %w", x.Name, err)
+		}
+		c.Options = append(c.Options, nats.UserJWTAndSeed(x.UserJWT, strings.TrimSpace(string(seed))))
+	} else {
+		set(&c.NKeyFile, expandPath(x.NKey))
+	}
+
+	if x.SocksProxy != "" {
+		addr := x.SocksProxy
+		if !strings.Contains(addr, "://") {
+			addr = "socks5://" + addr
+		}
+		if _, err := parseProxyURL(addr); err != nil {
+			return fmt.Errorf("nats: context %q: %w", x.Name, err)
+		}
+		c.Proxy = addr
+	}
+	return nil
+}
+
+// WithNATSContext 从 nats CLI 上下文读取配置，name 为空时使用当前选中的上下文
+func WithNATSContext(name string) Option {
+	return func(c *Config) error {
+		nctx, err := LoadNATSContext(name)
+		if err != nil {
+			return err
+		}
+		return nctx.Apply(c)
+	}
+}
+
+// ConnectNATSContext 使用 nats CLI 上下文建立连接并创建 JetStream 客户端，
+// opts 在上下文之后应用，可以覆盖其中的字段
+func ConnectNATSContext(ctx context.Context, name string, opts ...Option) (*nats.Conn, *JetStream, error) {
+	cfg, err := NewConfig(append([]Option{WithNATSContext(name)}, opts...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg.ConnectJetStream(ctx)
+}
+
+// expandPath 展开路径中的 ~ 与环境变量
+func expandPath(p string) string {
+	if p == "" {
+		return p
+	}
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
@@ -0,0 +1,135 @@
+package nats_client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// writeNATSContext 在临时 XDG_CONFIG_HOME 下写入上下文文件
+func writeNATSContext(t *testing.T, name, data string) string {
+	t.Helper()
+	base := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", base)
+	t.Setenv("NATS_CONTEXT", "")
+	dir := filepath.Join(base, "nats", "context")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return base
+}
+
+// TestLoadNATSContext 测试读取 nats CLI 上下文并转换为 Config
+func TestLoadNATSContext(t *testing.T) {
+	writeNATSContext(t, "prod", `{
+		"description": "生产集群",
+		"url": "nats://prod-1:4222,nats://prod-2:4222",
+		"creds": "$HOME/prod.creds",
+		"ca": "/etc/nats/ca.pem",
+		"jetstream_domain": "hub",
+		"inbox_prefix": "_INBOX_prod",
+		"socks_proxy": "127.0.0.1:1080",
+		"tls_first": true
+	}`)
+	t.Setenv("HOME", "/home/tester")
+
+	cfg, err := NewConfig(WithNATSContext("prod"))
+	if err != nil {
+		t.Fatalf("读取上下文失败: %v", err)
+	}
+	if cfg.URL != "nats://prod-1:4222,nats://prod-2:4222" {
+		t.Errorf("URL 不匹配: %q", cfg.URL)
+	}
+	if cfg.CredsFile != "/home/tester/prod.creds" || cfg.CAFile != "/etc/nats/ca.pem" {
+		t.Errorf("证书路径不匹配: %q %q", cfg.CredsFile, cfg.CAFile)
+	}
+	if cfg.JetStream.Domain != "hub" || cfg.InboxPrefix != "_INBOX_prod" || !cfg.TLSHandshakeFirst {
+		t.Errorf("JetStream/收件箱配置不匹配: %+v", cfg)
+	}
+	if !strings.HasPrefix(cfg.Proxy, "socks5://") {
+		t.Errorf("socks_proxy 未生效: %q", cfg.Proxy)
+	}
+
+	cfg.CredsFile, cfg.CAFile = "", ""
+	o := applyOptions(t, cfg)
+	if o.InboxPrefix != "_INBOX_prod" || !o.TLSHandshakeFirst {
+		t.Errorf("nats 选项不匹配: %+v", o)
+	}
+}
+
+// TestSelectedNATSContext 测试上下文的选择顺序
+func TestSelectedNATSContext(t *testing.T) {
+	base := writeNATSContext(t, "dev", `{"url":"nats://dev:4222"}`)
+
+	if got := SelectedNATSContext(); got != "" {
+		t.Errorf("未选择上下文时应为空: %q", got)
+	}
+	if _, err := LoadNATSContext(""); err == nil {
+		t.Error("未选择上下文时期望报错")
+	}
+
+	if err := os.WriteFile(filepath.Join(base, "nats", "context.txt"), []byte("dev\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	nctx, err := LoadNATSContext("")
+	if err != nil {
+		t.Fatalf("读取 context.txt 选中的上下文失败: %v", err)
+	}
+	if nctx.Name != "dev" || nctx.URL != "nats://dev:4222" {
+		t.Errorf("上下文不匹配: %+v", nctx)
+	}
+
+	t.Setenv("NATS_CONTEXT", "missing")
+	if _, err := LoadNATSContext(""); err == nil {
+		t.Error("NATS_CONTEXT 应优先于 context.txt")
+	}
+}
+
+// TestNATSContextErrors 测试不支持或不完整的上下文
+func TestNATSContextErrors(t *testing.T) {
+	writeNATSContext(t, "nsc", `{"nsc":"nsc://op/acct/user"}`)
+	if _, err := NewConfig(WithNATSContext("nsc")); err == nil {
+		t.Error("nsc 查找应报错")
+	}
+	writeNATSContext(t, "jwt", `{"user_jwt":"eyJ..."}`)
+	if _, err := NewConfig(WithNATSContext("jwt")); err == nil {
+		t.Error("缺少 nkey 的 user_jwt 应报错")
+	}
+	if _, err := LoadNATSContext("../etc/passwd"); err == nil {
+		t.Error("非法的上下文名称应报错")
+	}
+}
+
+// TestNewNATSConnectContext 测试 NewNATSConnect 读取 NATS_CONTEXT，环境变量优先
+func TestNewNATSConnectContext(t *testing.T) {
+	s1, s2 := natstest.RunServer(t), natstest.RunServer(t)
+	writeNATSContext(t, "test", fmt.Sprintf(`{"url":%q,"inbox_prefix":"_INBOX_ctx"}`, s1.ClientURL()))
+	t.Setenv("NATS_CONTEXT", "test")
+	t.Setenv("NATS_URL", "")
+
+	nc, err := NewNATSConnect()
+	if err != nil {
+		t.Fatalf("使用上下文连接失败: %v", err)
+	}
+	defer nc.Close()
+	if nc.ConnectedUrl() != s1.ClientURL() || nc.Opts.InboxPrefix != "_INBOX_ctx" {
+		t.Errorf("上下文未生效: %s %q", nc.ConnectedUrl(), nc.Opts.InboxPrefix)
+	}
+
+	t.Setenv("NATS_URL", s2.ClientURL())
+	nc2, err := NewNATSConnect()
+	if err != nil {
+		t.Fatalf("使用上下文与环境变量连接失败: %v", err)
+	}
+	defer nc2.Close()
+	if nc2.ConnectedUrl() != s2.ClientURL() || nc2.Opts.InboxPrefix != "_INBOX_ctx" {
+		t.Errorf("NATS_URL 应覆盖上下文中的地址: %s %q", nc2.ConnectedUrl(), nc2.Opts.InboxPrefix)
+	}
+}
@@ -1,22 +1,25 @@
 package nats_client
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/nats-io/nats.go"
-
-	"golang.org/x/net/proxy"
+	"github.com/zjzhang-cn/nats-client/natstest"
 )
 
 // TestNATSConnection 测试 NATS 连接功能
 func TestNATSConnection(t *testing.T) {
 	// 准备测试环境
+	setupTestEnv(t)
 	nc, err := NewNATSConnect()
 	if err != nil {
-		log.Fatalf("连接失败: %v", err)
+		t.Fatalf("连接失败: %v", err)
 	}
 	defer nc.Close()
 
@@ -32,10 +35,11 @@ func TestJetStreamContext(t *testing.T) {
 	defer nc.Drain()
 
 	// 获取 JetStream 上下文
-	js, err := nc.JetStream(nats.Domain("hub"))
+	jsc, err := testJetStream(nc)
 	if err != nil {
 		t.Fatalf("获取JetStream失败: %v", err)
 	}
+	js := jsc.Legacy
 
 	// 验证 JetStream 上下文
 	if js == nil {
@@ -49,18 +53,21 @@ func TestJetStreamContext(t *testing.T) {
 func TestQueueSubscribe(t *testing.T) {
 	nc := setupTestConnection(t)
 	defer nc.Drain()
+	log := testLogger(t)
 
-	js, err := nc.JetStream(nats.Domain("hub"))
+	jsc, err := testJetStream(nc)
 	if err != nil {
 		t.Fatalf("获取JetStream失败: %v", err)
 	}
+	js := jsc.Legacy
+	setupEventsStream(t, js)
 
 	// 创建测试用的消息接收通道
 	msgReceived := make(chan *nats.Msg, 1)
 
 	// 设置队列订阅
 	sub, err := js.QueueSubscribe("events.test", "test_consumer", func(msg *nats.Msg) {
-		log.Printf("[TEST] 收到消息: %s %s", msg.Subject, string(msg.Data))
+		log.Info("message received", LogKeySubject, msg.Subject, "data", string(msg.Data))
 		msgReceived <- msg
 		msg.Ack()
 	}, nats.Durable("test-worker-group"))
@@ -96,18 +103,21 @@ func TestQueueSubscribe(t *testing.T) {
 func TestMultipleMessages(t *testing.T) {
 	nc := setupTestConnection(t)
 	defer nc.Drain()
+	log := testLogger(t)
 
-	js, err := nc.JetStream(nats.Domain("hub"))
+	jsc, err := testJetStream(nc)
 	if err != nil {
 		t.Fatalf("获取JetStream失败: %v", err)
 	}
+	js := jsc.Legacy
+	setupEventsStream(t, js)
 
 	const messageCount = 5
 	msgReceived := make(chan *nats.Msg, messageCount)
 
 	// 设置订阅
 	sub, err := js.QueueSubscribe("events.multi", "multi_consumer", func(msg *nats.Msg) {
-		log.Printf("[TEST] 收到消息 #%s: %s", msg.Subject, string(msg.Data))
+		log.Info("message received", LogKeySubject, msg.Subject, "data", string(msg.Data))
 		msgReceived <- msg
 		msg.Ack()
 	}, nats.Durable("test-multi-group"))
@@ -156,10 +166,11 @@ func TestErrorHandling(t *testing.T) {
 	nc := setupTestConnection(t)
 	defer nc.Drain()
 
-	_, err = nc.JetStream(nats.Domain("invalid-domain"))
-	if err != nil {
-		t.Logf("正确处理了JetStream域错误: %v", err)
+	_, err = NewJetStream(context.Background(), nc, JetStreamConfig{Domain: "invalid-domain", Timeout: time.Second})
+	if !errors.Is(err, ErrJetStreamUnavailable) {
+		t.Fatalf("期望 ErrJetStreamUnavailable，实际: %v", err)
 	}
+	t.Logf("正确处理了JetStream域错误: %v", err)
 }
 
 // BenchmarkQueueSubscribe 性能基准测试
@@ -167,10 +178,12 @@ func BenchmarkQueueSubscribe(b *testing.B) {
 	nc := setupBenchConnection(b)
 	defer nc.Drain()
 
-	js, err := nc.JetStream(nats.Domain("hub"))
+	jsc, err := testJetStream(nc)
 	if err != nil {
 		b.Fatalf("获取JetStream失败: %v", err)
 	}
+	js := jsc.Legacy
+	setupEventsStream(b, js)
 
 	msgReceived := make(chan *nats.Msg, b.N)
 
@@ -204,47 +217,114 @@ func BenchmarkQueueSubscribe(b *testing.B) {
 	}
 }
 
-// setupTestConnection 设置测试连接的辅助函数
-func setupTestConnection(t *testing.T) *nats.Conn {
-	opts := []nats.Option{nats.Name("nats-client-test")}
+// testJetStream 通过 JetStream 工厂获取测试使用的客户端，域默认为 hub，可由 NATS_DOMAIN 覆盖
+func testJetStream(nc *nats.Conn) (*JetStream, error) {
+	domain := os.Getenv("NATS_DOMAIN")
+	if domain == "" {
+		domain = "hub"
+	}
+	return NewJetStream(context.Background(), nc, JetStreamConfig{Domain: domain})
+}
 
-	opts = append(opts, nats.SetCustomDialer(proxy.FromEnvironment()))
+// testServerURL 返回测试使用的服务器地址：
+// 设置了 NATS_TEST_URL 时使用外部服务器，否则启动内嵌服务器
+func testServerURL(tb testing.TB) (string, bool) {
+	tb.Helper()
+	if natsUrl := os.Getenv("NATS_TEST_URL"); natsUrl != "" {
+		return natsUrl, true
+	}
+	return natstest.RunServer(tb).ClientURL(), false
+}
 
-	natsUrl := os.Getenv("NATS_TEST_URL")
+// setupTestEnv 让 NewNATSConnect 连接到测试服务器
+func setupTestEnv(t *testing.T) {
+	t.Helper()
+	natsUrl, _ := testServerURL(t)
+	t.Setenv("NATS_URL", natsUrl)
+}
+
+// connectTestServer 连接测试服务器，只有外部服务器才使用代理
+func connectTestServer(tb testing.TB, name string) *nats.Conn {
+	tb.Helper()
+	opts := []nats.Option{nats.Name(name)}
+
+	natsUrl, external := testServerURL(tb)
+	if external {
+		opts = append(opts, nats.SetCustomDialer(ProxyFromEnvironment()))
+	}
 
 	nc, err := nats.Connect(natsUrl, opts...)
 	if err != nil {
-		t.Fatalf("NATS连接失败: %v", err)
+		tb.Fatalf("NATS连接失败: %v", err)
 	}
 
 	return nc
 }
 
+// setupTestConnection 设置测试连接的辅助函数
+func setupTestConnection(t *testing.T) *nats.Conn {
+	return connectTestServer(t, "nats-client-test")
+}
+
+// setupTestClusterConnection 连接三节点 JetStream 集群，用于需要多副本的测试；
+// 设置了 NATS_TEST_URL 时连接外部服务器，返回的 Cluster 为 nil
+func setupTestClusterConnection(t *testing.T) (*nats.Conn, *natstest.Cluster) {
+	t.Helper()
+	if os.Getenv("NATS_TEST_URL") != "" {
+		return setupTestConnection(t), nil
+	}
+	cluster := natstest.RunCluster(t, 3)
+	nc, err := nats.Connect(cluster.URLs(),
+		nats.Name("nats-client-test"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NATS集群连接失败: %v", err)
+	}
+	return nc, cluster
+}
+
 // setupBenchConnection 设置基准测试连接的辅助函数
 func setupBenchConnection(b *testing.B) *nats.Conn {
-	opts := []nats.Option{nats.Name("nats-client-bench")}
-	opts = append(opts, nats.SetCustomDialer(proxy.FromEnvironment()))
+	return connectTestServer(b, "nats-client-bench")
+}
 
-	natsUrl := os.Getenv("NATS_TEST_URL")
+// testLogger 返回写入 tb.Log 的 slog.Logger，只在测试失败或 -v 时输出
+func testLogger(tb testing.TB) *slog.Logger {
+	return slog.New(slog.NewTextHandler(testLogWriter{tb}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
 
-	nc, err := nats.Connect(natsUrl, opts...)
-	if err != nil {
-		b.Fatalf("NATS连接失败: %v", err)
-	}
+type testLogWriter struct{ tb testing.TB }
 
-	return nc
+func (w testLogWriter) Write(p []byte) (int, error) {
+	w.tb.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// setupEventsStream 确保 events.> 对应的 EVENTS 流存在
+func setupEventsStream(tb testing.TB, js nats.JetStreamContext) {
+	tb.Helper()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "EVENTS",
+		Subjects: []string{"events.>"},
+		Storage:  nats.FileStorage,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		tb.Fatalf("添加流失败: %v", err)
+	}
 }
 
 // TestMain 测试入口点，可以进行全局的测试设置和清理
 func TestMain(m *testing.M) {
 	// 测试前的全局设置
-	log.Println("开始运行 NATS 客户端测试...")
+	slog.Info("nats client tests starting")
 
 	// 运行测试
 	code := m.Run()
 
 	// 测试后的全局清理
-	log.Println("NATS 客户端测试完成")
+	slog.Info("nats client tests finished", "code", code)
 
 	os.Exit(code)
 }
@@ -0,0 +1,184 @@
+package natstest
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// Cluster 进程内的 JetStream 集群，节点之间通过回环地址上的路由互联
+type Cluster struct {
+	Name    string
+	Servers []*server.Server // 已停止的节点为 nil
+
+	tb   testing.TB
+	opts []*server.Options
+}
+
+// RunCluster 启动 size 个节点的 JetStream 集群并等待选出元数据 leader，
+// 测试结束时自动关闭所有节点
+func RunCluster(tb testing.TB, size int, opts ...Option) *Cluster {
+	tb.Helper()
+	if size < 1 {
+		tb.Fatalf("natstest: invalid cluster size %d", size)
+	}
+
+	// 预先分配端口，节点重启后仍使用相同的地址
+	ports := freePorts(tb, 2*size)
+	routes := make([]string, size)
+	for i := range routes {
+		routes[i] = fmt.Sprintf("nats://127.0.0.1:%d", ports[size+i])
+	}
+
+	c := &Cluster{Name: "natstest", tb: tb, Servers: make([]*server.Server, size)}
+	base := tb.TempDir()
+	for i := 0; i < size; i++ {
+		o := DefaultOptions()
+		o.ServerName = fmt.Sprintf("%s-%d", c.Name, i)
+		o.Port = ports[i]
+		o.StoreDir = filepath.Join(base, o.ServerName)
+		o.Cluster.Name = c.Name
+		o.Cluster.Host = "127.0.0.1"
+		o.Cluster.Port = ports[size+i]
+		o.Routes = server.RoutesFromStr(strings.Join(routes, ","))
+		for _, opt := range opts {
+			opt(o)
+		}
+		c.opts = append(c.opts, o)
+	}
+
+	tb.Cleanup(c.Shutdown)
+	for i := range c.opts {
+		c.start(i)
+	}
+	c.WaitForLeader()
+	return c
+}
+
+// freePorts 分配 n 个空闲端口
+func freePorts(tb testing.TB, n int) []int {
+	tb.Helper()
+	ports := make([]int, n)
+	var ls []net.Listener
+	for i := range ports {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			tb.Fatalf("natstest: allocate port: %v", err)
+		}
+		ls = append(ls, l)
+		ports[i] = l.Addr().(*net.TCPAddr).Port
+	}
+	for _, l := range ls {
+		l.Close()
+	}
+	return ports
+}
+
+func (c *Cluster) start(i int) {
+	c.tb.Helper()
+	o := *c.opts[i]
+	s, err := server.NewServer(&o)
+	if err != nil {
+		c.tb.Fatalf("natstest: new server %d: %v", i, err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(readyTimeout) {
+		s.Shutdown()
+		c.tb.Fatalf("natstest: server %d not ready after %v", i, readyTimeout)
+	}
+	c.Servers[i] = s
+}
+
+// URLs 返回所有节点的客户端地址，用逗号分隔，可直接传给 nats.Connect
+func (c *Cluster) URLs() string {
+	urls := make([]string, len(c.opts))
+	for i, o := range c.opts {
+		urls[i] = fmt.Sprintf("nats://%s:%d", o.Host, o.Port)
+	}
+	return strings.Join(urls, ",")
+}
+
+// Kill 停止第 i 个节点，数据目录保留以便 Restart
+func (c *Cluster) Kill(i int) {
+	if s := c.Servers[i]; s != nil {
+		s.Shutdown()
+		s.WaitForShutdown()
+		c.Servers[i] = nil
+	}
+}
+
+// Restart 使用原来的端口与数据目录重新启动第 i 个节点
+func (c *Cluster) Restart(i int) *server.Server {
+	c.tb.Helper()
+	c.Kill(i)
+	c.start(i)
+	c.WaitForLeader()
+	return c.Servers[i]
+}
+
+// Shutdown 停止所有节点
+func (c *Cluster) Shutdown() {
+	for i := range c.Servers {
+		c.Kill(i)
+	}
+}
+
+// Leader 返回元数据 leader 的下标，没有 leader 时返回 -1
+func (c *Cluster) Leader() int {
+	for i, s := range c.Servers {
+		if s != nil && s.JetStreamIsLeader() {
+			return i
+		}
+	}
+	return -1
+}
+
+// StreamLeader 返回默认账户下流 stream 的 leader 下标，没有 leader 时返回 -1
+func (c *Cluster) StreamLeader(stream string) int {
+	for i, s := range c.Servers {
+		if s != nil && s.JetStreamIsStreamLeader(server.DEFAULT_GLOBAL_ACCOUNT, stream) {
+			return i
+		}
+	}
+	return -1
+}
+
+// WaitForLeader 等待选出元数据 leader 且所有运行中的节点已同步
+func (c *Cluster) WaitForLeader() {
+	c.tb.Helper()
+	c.waitFor("meta leader", func() bool {
+		if c.Leader() < 0 {
+			return false
+		}
+		for _, s := range c.Servers {
+			if s != nil && !s.JetStreamIsCurrent() {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WaitForStreamLeader 等待流 stream 选出 leader，返回其下标
+func (c *Cluster) WaitForStreamLeader(stream string) int {
+	c.tb.Helper()
+	c.waitFor("stream leader "+stream, func() bool { return c.StreamLeader(stream) >= 0 })
+	return c.StreamLeader(stream)
+}
+
+func (c *Cluster) waitFor(what string, ok func() bool) {
+	c.tb.Helper()
+	deadline := time.Now().Add(readyTimeout)
+	for time.Now().Before(deadline) {
+		if ok() {
+			return
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+	c.tb.Fatalf("natstest: timed out waiting for %s", what)
+}
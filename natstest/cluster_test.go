@@ -0,0 +1,65 @@
+package natstest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// TestClusterFailover 测试 R3 流在 leader 节点停止后继续可用，节点重启后数据完整
+func TestClusterFailover(t *testing.T) {
+	c := RunCluster(t, 3)
+	nc, err := nats.Connect(c.URLs(), nats.MaxReconnects(-1), nats.ReconnectWait(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("连接集群失败: %v", err)
+	}
+	defer nc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	js, err := jetstream.NewWithDomain(nc, Domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     "ORDERS",
+		Subjects: []string{"orders.>"},
+		Replicas: 3,
+		Storage:  jetstream.FileStorage,
+	}); err != nil {
+		t.Fatalf("创建 R3 流失败: %v", err)
+	}
+
+	publish := func(from, to int) {
+		for i := from; i < to; i++ {
+			if _, err := js.Publish(ctx, "orders.new", []byte(fmt.Sprint(i)), jetstream.WithRetryAttempts(20)); err != nil {
+				t.Fatalf("发布消息 %d 失败: %v", i, err)
+			}
+		}
+	}
+	publish(0, 10)
+
+	leader := c.WaitForStreamLeader("ORDERS")
+	c.Kill(leader)
+	newLeader := c.WaitForStreamLeader("ORDERS")
+	if newLeader == leader {
+		t.Fatalf("leader 未切换: %d", newLeader)
+	}
+	publish(10, 20)
+
+	c.Restart(leader)
+	stream, err := js.Stream(ctx, "ORDERS")
+	if err != nil {
+		t.Fatalf("读取流失败: %v", err)
+	}
+	if msgs := stream.CachedInfo().State.Msgs; msgs != 20 {
+		t.Errorf("消息数量不匹配: got %d, want 20", msgs)
+	}
+	if n := len(stream.CachedInfo().Cluster.Replicas); n != 2 {
+		t.Errorf("副本数量不匹配: got %d, want 2", n)
+	}
+}
@@ -0,0 +1,284 @@
+package natstest
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Proxy 位于客户端与服务器之间的 TCP 代理，可以注入延迟、带宽限制、
+// 停顿、连接重置和半开连接等网络故障
+type Proxy struct {
+	target string
+	ln     net.Listener
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	latency   time.Duration
+	bandwidth int64 // 每个方向每秒字节数，0 表示不限制
+	stalled   bool
+	halfOpen  bool
+	refuse    bool
+	closed    bool
+	conns     map[*proxyConn]struct{}
+	wg        sync.WaitGroup
+}
+
+// proxyConn 一对被代理的连接
+type proxyConn struct {
+	client net.Conn
+	server net.Conn
+}
+
+// NewProxy 在随机端口上启动代理，转发到 target (host:port)
+func NewProxy(target string) (*Proxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("natstest: proxy listen: %w", err)
+	}
+	p := &Proxy{target: target, ln: ln, conns: make(map[*proxyConn]struct{})}
+	p.cond = sync.NewCond(&p.mu)
+	p.wg.Add(1)
+	go p.accept()
+	return p, nil
+}
+
+// RunProxy 启动代理，测试结束时自动关闭
+func RunProxy(tb testing.TB, target string) *Proxy {
+	tb.Helper()
+	p, err := NewProxy(target)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(p.Close)
+	return p
+}
+
+// Addr 返回代理监听的地址
+func (p *Proxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+// URL 返回代理的 nats:// 地址
+func (p *Proxy) URL() string {
+	return "nats://" + p.Addr()
+}
+
+// SetLatency 设置每个方向的单程延迟
+func (p *Proxy) SetLatency(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency = d
+}
+
+// SetBandwidth 设置每个方向每秒允许转发的字节数，0 表示不限制
+func (p *Proxy) SetBandwidth(bytesPerSec int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bandwidth = bytesPerSec
+}
+
+// Stall 暂停转发，数据保留在代理中，Resume 后继续发送
+func (p *Proxy) Stall() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stalled = true
+}
+
+// HalfOpen 保持连接打开但丢弃所有数据，模拟对端已失联的半开连接
+func (p *Proxy) HalfOpen() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.halfOpen = true
+}
+
+// Refuse 拒绝新的连接，已有连接不受影响
+func (p *Proxy) Refuse(refuse bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refuse = refuse
+}
+
+// Resume 取消 Stall 与 HalfOpen，恢复正常转发
+func (p *Proxy) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stalled, p.halfOpen = false, false
+	p.cond.Broadcast()
+}
+
+// ResetConnections 以 RST 关闭所有已有连接，代理中尚未发送的数据被丢弃
+func (p *Proxy) ResetConnections() {
+	p.mu.Lock()
+	conns := make([]*proxyConn, 0, len(p.conns))
+	for pc := range p.conns {
+		conns = append(conns, pc)
+		delete(p.conns, pc)
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	for _, pc := range conns {
+		pc.reset()
+	}
+}
+
+// Fault 计划中的一次故障，在 Schedule 开始后 After 时刻执行 Do
+type Fault struct {
+	After time.Duration
+	Do    func(*Proxy)
+}
+
+// Schedule 在后台按计划依次执行故障，返回的通道在全部执行完后关闭
+func (p *Proxy) Schedule(faults ...Fault) <-chan struct{} {
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		defer close(done)
+		for _, f := range faults {
+			time.Sleep(time.Until(start.Add(f.After)))
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return
+			}
+			f.Do(p)
+		}
+	}()
+	return done
+}
+
+// Close 关闭代理与所有连接
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+	p.ln.Close()
+	p.ResetConnections()
+	p.wg.Wait()
+}
+
+func (p *Proxy) accept() {
+	defer p.wg.Done()
+	for {
+		client, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		refuse := p.refuse || p.closed
+		p.mu.Unlock()
+		if refuse {
+			client.Close()
+			continue
+		}
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		pc := &proxyConn{client: client, server: server}
+		// 拨号期间 Close 可能已经重置了所有连接，此时登记的连接不会再被关闭
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			pc.close()
+			continue
+		}
+		p.conns[pc] = struct{}{}
+		p.wg.Add(2)
+		p.mu.Unlock()
+		go p.pipe(pc, client, server)
+		go p.pipe(pc, server, client)
+	}
+}
+
+// chunk 带有读取时间的数据块，用于计算延迟
+type chunk struct {
+	data []byte
+	at   time.Time
+}
+
+// pipe 将 src 的数据按当前故障设置转发到 dst
+func (p *Proxy) pipe(pc *proxyConn, src, dst net.Conn) {
+	defer p.wg.Done()
+	chunks := make(chan chunk, 64)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := src.Read(buf)
+			if n > 0 {
+				chunks <- chunk{data: buf[:n], at: time.Now()}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for c := range chunks {
+		if !p.wait(pc, c) {
+			break
+		}
+		p.mu.Lock()
+		drop, bw := p.halfOpen, p.bandwidth
+		p.mu.Unlock()
+		if drop {
+			continue
+		}
+		if _, err := dst.Write(c.data); err != nil {
+			break
+		}
+		if bw > 0 {
+			time.Sleep(time.Duration(int64(len(c.data)) * int64(time.Second) / bw))
+		}
+	}
+	// 一个方向结束后关闭两端，另一个方向随之退出
+	pc.close()
+	p.mu.Lock()
+	delete(p.conns, pc)
+	p.mu.Unlock()
+	for range chunks {
+	}
+}
+
+// wait 等待延迟到期且未处于停顿状态，连接已被重置时返回 false
+func (p *Proxy) wait(pc *proxyConn, c chunk) bool {
+	p.mu.Lock()
+	latency := p.latency
+	p.mu.Unlock()
+	time.Sleep(time.Until(c.at.Add(latency)))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.stalled {
+		if _, ok := p.conns[pc]; !ok {
+			return false
+		}
+		p.cond.Wait()
+	}
+	_, ok := p.conns[pc]
+	return ok
+}
+
+func (pc *proxyConn) close() {
+	pc.client.Close()
+	pc.server.Close()
+}
+
+// reset 设置 SO_LINGER 为 0 后关闭，使对端收到 RST
+func (pc *proxyConn) reset() {
+	for _, c := range []net.Conn{pc.client, pc.server} {
+		if tc, ok := c.(*net.TCPConn); ok {
+			tc.SetLinger(0)
+		}
+		c.Close()
+	}
+}
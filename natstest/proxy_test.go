@@ -0,0 +1,111 @@
+package natstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TestProxyLatency 测试延迟会反映在 RTT 上
+func TestProxyLatency(t *testing.T) {
+	s := RunServer(t, WithoutJetStream())
+	p := RunProxy(t, s.Addr().String())
+
+	nc, err := nats.Connect(p.URL())
+	if err != nil {
+		t.Fatalf("通过代理连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	p.SetLatency(50 * time.Millisecond)
+	rtt, err := nc.RTT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtt < 100*time.Millisecond {
+		t.Errorf("RTT 应包含往返延迟: %v", rtt)
+	}
+}
+
+// TestProxyBandwidth 测试带宽限制
+func TestProxyBandwidth(t *testing.T) {
+	s := RunServer(t, WithoutJetStream())
+	p := RunProxy(t, s.Addr().String())
+	nc, err := nats.Connect(p.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	p.SetBandwidth(64 * 1024)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		nc.Publish("bulk", make([]byte, 16*1024))
+	}
+	if err := nc.FlushTimeout(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 500*time.Millisecond {
+		t.Errorf("64KiB 在 64KiB/s 下应耗时约 1s，实际 %v", d)
+	}
+}
+
+// TestProxyStallAndReset 测试停顿时请求超时，重置后客户端重连
+func TestProxyStallAndReset(t *testing.T) {
+	s := RunServer(t, WithoutJetStream())
+	p := RunProxy(t, s.Addr().String())
+
+	reconnected := make(chan struct{}, 1)
+	nc, err := nats.Connect(p.URL(),
+		nats.ReconnectWait(20*time.Millisecond),
+		nats.ReconnectHandler(func(*nats.Conn) { reconnected <- struct{}{} }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	p.Stall()
+	if err := nc.FlushTimeout(100 * time.Millisecond); err == nil {
+		t.Error("停顿时 Flush 应超时")
+	}
+
+	done := p.Schedule(Fault{After: 10 * time.Millisecond, Do: (*Proxy).ResetConnections}, Fault{After: 20 * time.Millisecond, Do: (*Proxy).Resume})
+	<-done
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("重置连接后未重连")
+	}
+	if err := nc.FlushTimeout(time.Second); err != nil {
+		t.Errorf("重连后连接不可用: %v", err)
+	}
+}
+
+// TestProxyHalfOpen 测试半开连接被客户端心跳检测为失效
+func TestProxyHalfOpen(t *testing.T) {
+	s := RunServer(t, WithoutJetStream())
+	p := RunProxy(t, s.Addr().String())
+
+	disconnected := make(chan error, 1)
+	nc, err := nats.Connect(p.URL(),
+		nats.PingInterval(50*time.Millisecond),
+		nats.MaxPingsOutstanding(2),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) { disconnected <- err }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	p.HalfOpen()
+	select {
+	case err := <-disconnected:
+		if err != nats.ErrStaleConnection {
+			t.Errorf("期望 ErrStaleConnection，实际 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("半开连接未被检测到")
+	}
+}
@@ -0,0 +1,148 @@
+// Package natstest 提供测试用的内嵌 nats-server，
+// 测试无需依赖 NATS_URL/NATS_TEST_URL 指向的外部服务器。
+package natstest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// Domain 内嵌服务器默认的 JetStream 域，与线上集群保持一致
+const Domain = "hub"
+
+// 等待服务器就绪的超时
+const readyTimeout = 10 * time.Second
+
+// Option 修改内嵌服务器的配置
+type Option func(*server.Options)
+
+// WithoutJetStream 关闭 JetStream
+func WithoutJetStream() Option {
+	return func(o *server.Options) {
+		o.JetStream = false
+		o.JetStreamDomain = ""
+	}
+}
+
+// WithDomain 设置 JetStream 域
+func WithDomain(domain string) Option {
+	return func(o *server.Options) {
+		o.JetStreamDomain = domain
+	}
+}
+
+// WithPort 使用固定端口，用于重启后客户端能重连到同一地址
+func WithPort(port int) Option {
+	return func(o *server.Options) {
+		o.Port = port
+	}
+}
+
+// WithStoreDir 使用指定的 JetStream 存储目录，用于重启后保留数据
+func WithStoreDir(dir string) Option {
+	return func(o *server.Options) {
+		o.StoreDir = dir
+	}
+}
+
+// WithWebSocket 在 port 上开启 WebSocket 监听，tc 为 nil 时不使用 TLS
+func WithWebSocket(port int, tc *tls.Config) Option {
+	return func(o *server.Options) {
+		o.Websocket.Host = "127.0.0.1"
+		o.Websocket.Port = port
+		o.Websocket.NoTLS = tc == nil
+		o.Websocket.TLSConfig = tc
+	}
+}
+
+// FreePort 返回一个当前空闲的本地端口
+func FreePort(tb testing.TB) int {
+	tb.Helper()
+	return freePorts(tb, 1)[0]
+}
+
+// DefaultOptions 返回监听随机端口、启用 JetStream 的默认配置
+func DefaultOptions() *server.Options {
+	return &server.Options{
+		ServerName:      "natstest",
+		Host:            "127.0.0.1",
+		Port:            server.RANDOM_PORT,
+		NoLog:           true,
+		NoSigs:          true,
+		JetStream:       true,
+		JetStreamDomain: Domain,
+	}
+}
+
+// StartServer 启动内嵌服务器，调用方负责 Shutdown；
+// 未指定存储目录时使用新的临时目录，服务器关闭后删除
+func StartServer(opts ...Option) (*server.Server, error) {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	tmp := ""
+	if o.JetStream && o.StoreDir == "" {
+		dir, err := os.MkdirTemp("", "natstest-")
+		if err != nil {
+			return nil, err
+		}
+		o.StoreDir, tmp = dir, dir
+	}
+	cleanup := func() {
+		if tmp != "" {
+			os.RemoveAll(tmp)
+		}
+	}
+
+	s, err := server.NewServer(o)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("natstest: new server: %w", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(readyTimeout) {
+		s.Shutdown()
+		cleanup()
+		return nil, fmt.Errorf("natstest: server not ready after %v", readyTimeout)
+	}
+	if tmp != "" {
+		go func() {
+			s.WaitForShutdown()
+			cleanup()
+		}()
+	}
+	return s, nil
+}
+
+// RunServer 启动内嵌服务器，测试结束时自动关闭
+func RunServer(tb testing.TB, opts ...Option) *server.Server {
+	tb.Helper()
+	opts = append([]Option{WithStoreDir(tb.TempDir())}, opts...)
+	s, err := StartServer(opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		s.Shutdown()
+		s.WaitForShutdown()
+	})
+	return s
+}
+
+// Connect 连接到内嵌服务器，测试结束时自动关闭连接
+func Connect(tb testing.TB, s *server.Server, opts ...nats.Option) *nats.Conn {
+	tb.Helper()
+	nc, err := nats.Connect(s.ClientURL(), opts...)
+	if err != nil {
+		tb.Fatalf("natstest: connect: %v", err)
+	}
+	tb.Cleanup(nc.Close)
+	return nc
+}
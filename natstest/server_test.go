@@ -0,0 +1,62 @@
+package natstest
+
+import (
+	"net"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TestRunServer 测试内嵌服务器启用了 hub 域的 JetStream
+func TestRunServer(t *testing.T) {
+	s := RunServer(t)
+	if !s.JetStreamEnabled() {
+		t.Fatal("JetStream 未启用")
+	}
+	nc := Connect(t, s)
+	js, err := nc.JetStream(nats.Domain(Domain))
+	if err != nil {
+		t.Fatalf("获取JetStream失败: %v", err)
+	}
+	if _, err := js.AccountInfo(); err != nil {
+		t.Fatalf("hub 域不可达: %v", err)
+	}
+}
+
+// TestRestartKeepsData 测试固定端口与存储目录重启后数据仍在
+func TestRestartKeepsData(t *testing.T) {
+	dir := t.TempDir()
+	s := RunServer(t, WithStoreDir(dir))
+	port := s.Addr().(*net.TCPAddr).Port
+
+	nc := Connect(t, s)
+	js, _ := nc.JetStream(nats.Domain(Domain))
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "KEEP", Subjects: []string{"keep"}}); err != nil {
+		t.Fatalf("添加流失败: %v", err)
+	}
+	if _, err := js.Publish("keep", []byte("1")); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+	nc.Close()
+	s.Shutdown()
+	s.WaitForShutdown()
+
+	s = RunServer(t, WithStoreDir(dir), WithPort(port))
+	nc = Connect(t, s)
+	js, _ = nc.JetStream(nats.Domain(Domain))
+	info, err := js.StreamInfo("KEEP")
+	if err != nil {
+		t.Fatalf("重启后读取流失败: %v", err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("重启后消息数量不匹配: %d", info.State.Msgs)
+	}
+}
+
+// TestWithoutJetStream 测试关闭 JetStream
+func TestWithoutJetStream(t *testing.T) {
+	s := RunServer(t, WithoutJetStream())
+	if s.JetStreamEnabled() {
+		t.Error("JetStream 不应启用")
+	}
+}
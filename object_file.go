@@ -0,0 +1,358 @@
+package nats_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// UploadFile 记录在对象元数据中的文件属性，DownloadFile 据此校验内容并恢复文件属性
+const (
+	MetaSHA256      = "sha256"       // 文件内容的 SHA-256，十六进制
+	MetaMode        = "mode"         // 文件权限，八进制，例如 0644
+	MetaModTime     = "mtime"        // 文件修改时间，RFC 3339
+	MetaContentType = "content-type" // 内容类型，例如 application/octet-stream
+)
+
+// DigestError 下载内容的 SHA-256 与对象摘要或 sha256 元数据不一致。
+// errors.Is(err, jetstream.ErrDigestMismatch) 为 true
+type DigestError struct {
+	Object   string
+	Source   string // "digest" 为对象摘要，"metadata" 为 sha256 元数据
+	Expected string // 十六进制
+	Actual   string // 十六进制
+}
+
+func (e *DigestError) Error() string {
+	return fmt.Sprintf("nats: object %s: sha256 %s does not match %s %s", e.Object, e.Actual, e.Source, e.Expected)
+}
+
+func (e *DigestError) Is(target error) bool {
+	return target == jetstream.ErrDigestMismatch
+}
+
+// FileOption DownloadFile 与 UploadFile 的可选参数
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	progress func(Progress)
+	limiter  *RateLimiter
+	transfer *Transfer
+	meta     jetstream.ObjectMeta
+	force    bool   // 不检查同名对象的内容，调用方已经比较过
+	sum      []byte // 调用方已经算出的文件 SHA-256
+}
+
+// WithFileProgress 报告传输进度，节流规则与 ProgressReader 相同
+func WithFileProgress(cb func(Progress)) FileOption {
+	return func(o *fileOptions) { o.progress = cb }
+}
+
+// WithFileRateLimit 按 l 限速，多个传输共享 l 时为全局限速
+func WithFileRateLimit(l *RateLimiter) FileOption {
+	return func(o *fileOptions) { o.limiter = l }
+}
+
+// WithFileTransfer 把传输计入 ProgressTracker，结束时以最终结果 (含校验失败) 调用 tr.Done
+func WithFileTransfer(tr *Transfer) FileOption {
+	return func(o *fileOptions) { o.transfer = tr }
+}
+
+// WithFileMeta 上传时附加的描述、消息头与元数据，UploadFile 记录的文件属性优先
+func WithFileMeta(meta jetstream.ObjectMeta) FileOption {
+	return func(o *fileOptions) { o.meta = meta }
+}
+
+// withForceUpload 跳过 UploadFile 的内容比较，供已经比较过内容的 SyncUp 使用
+func withForceUpload() FileOption {
+	return func(o *fileOptions) { o.force = true }
+}
+
+// withFileDigest 使用调用方已经算出的文件 SHA-256，上传前不再读取文件计算
+func withFileDigest(sum []byte) FileOption {
+	return func(o *fileOptions) { o.sum = sum }
+}
+
+// DownloadFile 把对象下载到本地文件 path。内容先写入同目录的临时文件，
+// 校验 SHA-256 与对象摘要以及可选的 sha256 元数据一致后 fsync，再原子地重命名到 path。
+// 文件权限与修改时间取自 mode、mtime 元数据，没有时为 0644 与对象的修改时间。
+// 校验失败时返回 *DigestError，path 保持不变
+func DownloadFile(ctx context.Context, store jetstream.ObjectStore, name, path string, opts ...FileOption) (info *jetstream.ObjectInfo, err error) {
+	var o fileOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.transfer != nil {
+		defer func() { o.transfer.Done(err) }()
+	}
+
+	result, err := store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	info, err = result.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	h := sha256.New()
+	var w io.Writer = io.MultiWriter(tmp, h)
+	if o.limiter != nil {
+		w = NewRateLimitedWriter(ctx, w, o.limiter)
+	}
+	var pw *ProgressWriter
+	if o.transfer != nil {
+		pw = o.transfer.Writer(ctx, w)
+		pw.OnProgress = o.progress
+	} else {
+		pw = NewProgressWriter(ctx, w, int64(info.Size), o.progress)
+	}
+	n, err := io.Copy(pw, result)
+	// nats.go 在读到结尾时也会校验摘要，这里以自己计算的结果返回 DigestError
+	if err != nil && !errors.Is(err, jetstream.ErrDigestMismatch) {
+		pw.Finish(err)
+		return nil, err
+	}
+	if err = verifyDownload(info, n, h.Sum(nil)); err != nil {
+		pw.Finish(err)
+		return nil, err
+	}
+	pw.Finish(nil)
+
+	mode, mtime := fileAttrs(info)
+	if err = tmp.Chmod(mode); err != nil {
+		return nil, err
+	}
+	if err = tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	if !mtime.IsZero() {
+		if err = os.Chtimes(tmp.Name(), mtime, mtime); err != nil {
+			return nil, err
+		}
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	syncDir(dir)
+	return info, nil
+}
+
+// fileAttrs 返回对象元数据中记录的文件权限与修改时间
+func fileAttrs(info *jetstream.ObjectInfo) (os.FileMode, time.Time) {
+	mode, mtime := os.FileMode(0644), info.ModTime
+	if v, err := strconv.ParseUint(info.Metadata[MetaMode], 8, 32); err == nil {
+		mode = os.FileMode(v).Perm()
+	}
+	if t, err := time.Parse(time.RFC3339Nano, info.Metadata[MetaModTime]); err == nil {
+		mtime = t
+	}
+	return mode, mtime
+}
+
+// UploadFile 把本地文件 path 上传为对象 name，在元数据中记录内容的 SHA-256、文件权限、
+// 修改时间与内容类型。同名对象大小一致时先计算本地文件的 SHA-256 比较，内容相同时不再上传，
+// 只在文件属性变化时更新元数据，此时 uploaded 为 false；内容不同时复用这次的结果，
+// sha256 随 Put 原子地写入，上传过程中文件被修改则删除写入的对象并返回错误。
+// 没有同名对象或大小不同时文件只读取一次，上传的同时计算 SHA-256，完成后写入元数据
+func UploadFile(ctx context.Context, store jetstream.ObjectStore, name, path string, opts ...FileOption) (info *jetstream.ObjectInfo, uploaded bool, err error) {
+	var o fileOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.transfer != nil {
+		defer func() { o.transfer.Done(err) }()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	if !st.Mode().IsRegular() {
+		return nil, false, fmt.Errorf("nats: %s is not a regular file", path)
+	}
+
+	meta := o.meta
+	meta.Name = name
+	meta.Metadata = maps.Clone(o.meta.Metadata)
+	if meta.Metadata == nil {
+		meta.Metadata = make(map[string]string)
+	}
+	meta.Metadata[MetaMode] = fmt.Sprintf("%04o", st.Mode().Perm())
+	meta.Metadata[MetaModTime] = st.ModTime().UTC().Format(time.RFC3339Nano)
+	meta.Metadata[MetaContentType] = contentType(f)
+
+	var existing *jetstream.ObjectInfo
+	if !o.force {
+		existing, err = store.GetInfo(ctx, name)
+		if err != nil && !errors.Is(err, jetstream.ErrObjectNotFound) {
+			return nil, false, err
+		}
+	}
+	// 只有大小相同时需要先比较内容，其他情况在上传的同时计算
+	sum := o.sum
+	if sum == nil && existing != nil && existing.Size == uint64(st.Size()) {
+		if sum, err = hashFile(f); err != nil {
+			return nil, false, err
+		}
+		if sameContent(existing, sum) {
+			meta.Metadata[MetaSHA256] = hex.EncodeToString(sum)
+			if existing.Description != meta.Description || !maps.Equal(existing.Metadata, meta.Metadata) {
+				if err = store.UpdateMeta(ctx, name, meta); err != nil {
+					return nil, false, err
+				}
+				if existing, err = store.GetInfo(ctx, name); err != nil {
+					return nil, false, err
+				}
+			}
+			if o.progress != nil {
+				o.progress(Progress{Total: st.Size(), Done: true})
+			}
+			return existing, false, nil
+		}
+	}
+	if sum != nil {
+		meta.Metadata[MetaSHA256] = hex.EncodeToString(sum)
+	}
+
+	h := sha256.New()
+	var r io.Reader = io.TeeReader(f, h)
+	if o.limiter != nil {
+		r = NewRateLimitedReader(ctx, r, o.limiter)
+	}
+	var pr *ProgressReader
+	if o.transfer != nil {
+		pr = o.transfer.Reader(ctx, r)
+		pr.OnProgress = o.progress
+	} else {
+		pr = NewProgressReader(ctx, r, st.Size(), o.progress)
+	}
+	if _, err = store.Put(ctx, meta, pr); err != nil {
+		return nil, false, err
+	}
+	streamed := h.Sum(nil)
+	if sum == nil {
+		meta.Metadata[MetaSHA256] = hex.EncodeToString(streamed)
+		if err = store.UpdateMeta(ctx, name, meta); err != nil {
+			return nil, false, err
+		}
+	} else if !bytes.Equal(streamed, sum) {
+		// 对象的 sha256 元数据与内容不符，留下它会让之后的下载都校验失败
+		err = fmt.Errorf("nats: %s changed during upload of object %s", path, name)
+		if derr := store.Delete(ctx, name); derr != nil {
+			err = errors.Join(err, derr)
+		}
+		return nil, false, err
+	}
+	// Put 返回的修改时间是客户端时间，以服务器记录的为准
+	if info, err = store.GetInfo(ctx, name); err != nil {
+		return nil, false, err
+	}
+	return info, true, nil
+}
+
+// hashFile 从头计算 f 的 SHA-256，之后把读取位置移回开头
+func hashFile(f *os.File) ([]byte, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// contentType 按扩展名推断内容类型，未知扩展名时根据文件开头的内容判断
+func contentType(f *os.File) string {
+	if ct := mime.TypeByExtension(filepath.Ext(f.Name())); ct != "" {
+		return ct
+	}
+	buf := make([]byte, 512)
+	n, _ := f.ReadAt(buf, 0)
+	return http.DetectContentType(buf[:n])
+}
+
+// sameContent 判断对象内容的 SHA-256 是否为 sum，优先使用 sha256 元数据，其次使用对象摘要
+func sameContent(info *jetstream.ObjectInfo, sum []byte) bool {
+	if meta := info.Metadata[MetaSHA256]; meta != "" {
+		return meta == hex.EncodeToString(sum)
+	}
+	want, err := jetstream.DecodeObjectDigest(info.Digest)
+	return err == nil && bytes.Equal(want, sum)
+}
+
+// verifyDownload 校验下载的大小、对象摘要与 sha256 元数据
+func verifyDownload(info *jetstream.ObjectInfo, n int64, sum []byte) error {
+	if uint64(n) != info.Size {
+		return fmt.Errorf("nats: object %s: downloaded %d bytes, expected %d", info.Name, n, info.Size)
+	}
+	actual := hex.EncodeToString(sum)
+	if info.Digest != "" {
+		want, err := jetstream.DecodeObjectDigest(info.Digest)
+		if err != nil {
+			return fmt.Errorf("nats: object %s: %w", info.Name, err)
+		}
+		if !bytes.Equal(want, sum) {
+			return &DigestError{Object: info.Name, Source: "digest", Expected: hex.EncodeToString(want), Actual: actual}
+		}
+	}
+	if meta := info.Metadata[MetaSHA256]; meta != "" {
+		want, err := hex.DecodeString(meta)
+		if err != nil || len(want) != sha256.Size {
+			return fmt.Errorf("nats: object %s: invalid %s metadata %q", info.Name, MetaSHA256, meta)
+		}
+		if !bytes.Equal(want, sum) {
+			return &DigestError{Object: info.Name, Source: "metadata", Expected: meta, Actual: actual}
+		}
+	}
+	return nil
+}
+
+// syncDir 尽力 fsync 目录，使重命名在断电后仍然有效，不支持的平台忽略错误
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
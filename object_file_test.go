@@ -0,0 +1,303 @@
+package nats_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// testObjectStore 在内嵌服务器上创建对象存储
+func testObjectStore(t *testing.T, bucket string) (jetstream.JetStream, jetstream.ObjectStore) {
+	t.Helper()
+	js, err := jetstream.NewWithDomain(natstest.Connect(t, natstest.RunServer(t)), natstest.Domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := js.CreateObjectStore(context.Background(), jetstream.ObjectStoreConfig{Bucket: bucket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js, obj
+}
+
+// assertNoTempFiles 检查目录中没有残留的临时文件
+func assertNoTempFiles(t *testing.T, dir string, want int) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != want {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("目录中的文件不匹配: %v", names)
+	}
+}
+
+// TestDownloadFile 测试下载校验通过后原子替换目标文件，并保留对象的修改时间
+func TestDownloadFile(t *testing.T) {
+	_, obj := testObjectStore(t, "files")
+	ctx := context.Background()
+	data := make([]byte, 300<<10)
+	rand.Read(data)
+	if _, err := obj.PutBytes(ctx, "app.bin", data); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "nested")
+	path := filepath.Join(dir, "app.bin")
+
+	var last Progress
+	info, err := DownloadFile(ctx, obj, "app.bin", path, WithFileProgress(func(p Progress) { last = p }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, data) {
+		t.Fatal("下载的内容不一致")
+	}
+	st, _ := os.Stat(path)
+	if !st.ModTime().Equal(info.ModTime) || st.Mode().Perm() != 0644 {
+		t.Errorf("文件属性不匹配: %v %v != %v", st.Mode(), st.ModTime(), info.ModTime)
+	}
+	if !last.Done || last.Err != nil || last.Bytes != int64(len(data)) {
+		t.Errorf("进度不匹配: %+v", last)
+	}
+	assertNoTempFiles(t, dir, 1)
+
+	// 再次下载覆盖已有文件，并计入汇总进度
+	tk := NewProgressTracker(nil)
+	if _, err := DownloadFile(ctx, obj, "app.bin", path, WithFileTransfer(tk.Add("app.bin", int64(len(data)))), WithFileRateLimit(NewRateLimiter(0, 0))); err != nil {
+		t.Fatal(err)
+	}
+	if p := tk.Progress(); p.Succeeded != 1 || p.Bytes != int64(len(data)) {
+		t.Errorf("汇总进度不匹配: %+v", p)
+	}
+
+	if _, err := DownloadFile(ctx, obj, "missing", path); !errors.Is(err, jetstream.ErrObjectNotFound) {
+		t.Errorf("期望 ErrObjectNotFound: %v", err)
+	}
+	assertNoTempFiles(t, dir, 1)
+}
+
+// TestDownloadFileDigestMismatch 测试内容与 sha256 元数据或对象摘要不一致时返回 DigestError 且不替换目标文件
+func TestDownloadFileDigestMismatch(t *testing.T) {
+	js, obj := testObjectStore(t, "files")
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.bin")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := "0000000000000000000000000000000000000000000000000000000000000000"
+	if _, err := obj.Put(ctx, jetstream.ObjectMeta{Name: "meta.bin", Metadata: map[string]string{MetaSHA256: wrong}}, bytes.NewReader([]byte("payload"))); err != nil {
+		t.Fatal(err)
+	}
+	tk := NewProgressTracker(nil)
+	_, err := DownloadFile(ctx, obj, "meta.bin", path, WithFileTransfer(tk.Add("meta.bin", 7)))
+	var de *DigestError
+	if !errors.As(err, &de) || de.Source != "metadata" || de.Expected != wrong || !errors.Is(err, jetstream.ErrDigestMismatch) {
+		t.Fatalf("期望元数据不一致: %v", err)
+	}
+	if s := tk.Progress().Items[0]; s.State != TransferFailed || !errors.Is(s.Err, jetstream.ErrDigestMismatch) {
+		t.Errorf("校验失败应标记传输失败: %+v", s)
+	}
+
+	// 篡改对象摘要：以新的元数据覆盖原有的对象信息
+	info, err := obj.PutBytes(ctx, "tampered.bin", []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.Digest = "SHA-256=" + base64.URLEncoding.EncodeToString(make([]byte, 32))
+	meta, _ := json.Marshal(info)
+	msg := nats.NewMsg("$O.files.M." + base64.URLEncoding.EncodeToString([]byte("tampered.bin")))
+	msg.Header.Set(jetstream.MsgRollup, jetstream.MsgRollupSubject)
+	msg.Data = meta
+	if _, err := js.PublishMsg(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	_, err = DownloadFile(ctx, obj, "tampered.bin", path)
+	if !errors.As(err, &de) || de.Source != "digest" {
+		t.Fatalf("期望对象摘要不一致: %v", err)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != "old" {
+		t.Errorf("校验失败不应替换目标文件: %q", got)
+	}
+	assertNoTempFiles(t, dir, 1)
+}
+
+// TestUploadFile 测试上传记录文件属性，内容相同时跳过上传，下载时恢复文件属性
+func TestUploadFile(t *testing.T) {
+	_, obj := testObjectStore(t, "files")
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("hello nats"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	os.Chtimes(path, mtime, mtime)
+
+	var last Progress
+	info, uploaded, err := UploadFile(ctx, obj, "docs/notes.txt", path,
+		WithFileProgress(func(p Progress) { last = p }),
+		WithFileMeta(jetstream.ObjectMeta{Description: "release notes", Metadata: map[string]string{"version": "1.0"}}),
+	)
+	if err != nil || !uploaded {
+		t.Fatalf("上传失败: %v %v", uploaded, err)
+	}
+	sum := sha256.Sum256([]byte("hello nats"))
+	want := map[string]string{
+		MetaSHA256:      hex.EncodeToString(sum[:]),
+		MetaMode:        "0640",
+		MetaModTime:     "2024-05-01T12:30:00.123456789Z",
+		MetaContentType: "text/plain; charset=utf-8",
+		"version":       "1.0",
+	}
+	if !maps.Equal(info.Metadata, want) || info.Description != "release notes" {
+		t.Errorf("元数据不匹配: %v %q", info.Metadata, info.Description)
+	}
+	if !last.Done || last.Bytes != 10 {
+		t.Errorf("进度不匹配: %+v", last)
+	}
+
+	// 内容与属性都未变化时不上传
+	again, uploaded, err := UploadFile(ctx, obj, "docs/notes.txt", path, WithFileMeta(jetstream.ObjectMeta{Description: "release notes", Metadata: map[string]string{"version": "1.0"}}))
+	if err != nil || uploaded || again.NUID != info.NUID || !again.ModTime.Equal(info.ModTime) {
+		t.Fatalf("相同内容不应上传: %v %v", uploaded, err)
+	}
+	// 只修改时间变化时更新元数据
+	touched := mtime.Add(time.Hour)
+	os.Chtimes(path, touched, touched)
+	again, uploaded, err = UploadFile(ctx, obj, "docs/notes.txt", path)
+	if err != nil || uploaded || again.NUID != info.NUID || again.Metadata[MetaModTime] != "2024-05-01T13:30:00.123456789Z" {
+		t.Fatalf("修改时间变化时只应更新元数据: %v %v %v", uploaded, err, again.Metadata)
+	}
+	// 大小相同而内容不同时重新上传
+	os.WriteFile(path, []byte("HELLO NATS"), 0640)
+	again, uploaded, err = UploadFile(ctx, obj, "docs/notes.txt", path)
+	if err != nil || !uploaded || again.NUID == info.NUID {
+		t.Fatalf("内容变化时应重新上传: %v %v", uploaded, err)
+	}
+
+	// 下载时恢复权限与修改时间
+	st, _ := os.Stat(path)
+	out := filepath.Join(dir, "out", "notes.txt")
+	if _, err := DownloadFile(ctx, obj, "docs/notes.txt", out); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.Stat(out)
+	if got.Mode().Perm() != 0640 || !got.ModTime().Equal(st.ModTime()) {
+		t.Errorf("文件属性未恢复: %v %v != %v", got.Mode(), got.ModTime(), st.ModTime())
+	}
+}
+
+// TestUploadFileDedupDigest 测试没有 sha256 元数据的对象按对象摘要判断内容相同
+func TestUploadFileDedupDigest(t *testing.T) {
+	_, obj := testObjectStore(t, "files")
+	ctx := context.Background()
+	data := make([]byte, 200<<10)
+	rand.Read(data)
+	put, err := obj.PutBytes(ctx, "blob", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "blob")
+	os.WriteFile(path, data, 0644)
+
+	tk := NewProgressTracker(nil)
+	info, uploaded, err := UploadFile(ctx, obj, "blob", path, WithFileTransfer(tk.Add("blob", int64(len(data)))))
+	if err != nil || uploaded || info.NUID != put.NUID {
+		t.Fatalf("相同内容不应上传: %v %v", uploaded, err)
+	}
+	if info.Metadata[MetaSHA256] == "" || info.Metadata[MetaContentType] != "application/octet-stream" {
+		t.Errorf("应补充文件属性元数据: %v", info.Metadata)
+	}
+	if p := tk.Progress(); p.Succeeded != 1 || p.Bytes != 0 {
+		t.Errorf("跳过的传输应为成功且没有传输字节: %+v", p)
+	}
+
+	if _, _, err := UploadFile(ctx, obj, "dir", t.TempDir()); err == nil {
+		t.Error("上传目录应返回错误")
+	}
+}
+
+// noUpdateMetaStore 上传时不允许调用 UpdateMeta，验证元数据随 Put 一起写入
+type noUpdateMetaStore struct {
+	jetstream.ObjectStore
+}
+
+func (s noUpdateMetaStore) UpdateMeta(ctx context.Context, name string, meta jetstream.ObjectMeta) error {
+	return errors.New("unexpected UpdateMeta")
+}
+
+// TestUploadFileAtomicMeta 测试大小相同而内容不同时复用比较时算出的 sha256，随 Put 原子地写入
+func TestUploadFileAtomicMeta(t *testing.T) {
+	_, obj := testObjectStore(t, "files")
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data.bin")
+	os.WriteFile(path, []byte("first"), 0644)
+	if _, _, err := UploadFile(ctx, obj, "data.bin", path); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("other"), 0644)
+	info, uploaded, err := UploadFile(ctx, noUpdateMetaStore{obj}, "data.bin", path)
+	if err != nil || !uploaded {
+		t.Fatalf("上传失败: %v %v", uploaded, err)
+	}
+	sum := sha256.Sum256([]byte("other"))
+	if info.Metadata[MetaSHA256] != hex.EncodeToString(sum[:]) {
+		t.Errorf("sha256 元数据不匹配: %v", info.Metadata)
+	}
+}
+
+// TestUploadFileChangedDuringUpload 测试上传过程中文件被修改时删除写入的对象，不留下 sha256 与内容不符的对象
+func TestUploadFileChangedDuringUpload(t *testing.T) {
+	_, obj := testObjectStore(t, "files")
+	ctx := context.Background()
+	const size = 1 << 20
+	old := make([]byte, size)
+	rand.Read(old)
+	if _, err := obj.PutBytes(ctx, "blob", old); err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, size)
+	rand.Read(data)
+	path := filepath.Join(t.TempDir(), "blob")
+	os.WriteFile(path, data, 0644)
+
+	var once sync.Once
+	_, _, err := UploadFile(ctx, obj, "blob", path, WithFileProgress(func(Progress) {
+		// 第一次读取之后修改文件末尾
+		once.Do(func() {
+			f, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			f.WriteAt([]byte("changed"), size-7)
+			f.Close()
+		})
+	}))
+	if err == nil {
+		t.Fatal("上传过程中文件被修改时应返回错误")
+	}
+	if _, err := obj.GetInfo(ctx, "blob"); !errors.Is(err, jetstream.ErrObjectNotFound) {
+		t.Errorf("内容与 sha256 不符的对象应被删除: %v", err)
+	}
+}
@@ -1,11 +1,14 @@
 package nats_client
 
 import (
+	"bytes"
 	"context"
-	"fmt"
-	"io"
-	"log"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/nats-io/nats.go/jetstream"
@@ -13,58 +16,60 @@ import (
 
 func TestObjectGet(t *testing.T) {
 	bucket := "my_object_store"
-	nc, err := NewNATSConnect()
-	if err != nil {
-		log.Fatalf("连接失败: %v", err)
-	}
+	nc := setupTestConnection(t)
 	defer nc.Close()
+	log := testLogger(t)
 	// nc.QueueSubscribe(subject, "queue", func(msg *nats.Msg) {
 	// 	log.Printf("Received a message on subject %s: %s", msg.Subject, string(msg.Data))
 	// })
 
 	ctx := context.WithoutCancel(context.Background())
-	js, err := jetstream.NewWithDomain(nc, "hub")
+	js, err := testJetStream(nc)
 	if err != nil {
-		log.Fatalf("创建 JetStream 客户端失败: %v", err)
+		t.Fatalf("创建 JetStream 客户端失败: %v", err)
 	}
 
 	obj, err := js.ObjectStore(ctx, bucket)
-	if err != nil {
-		log.Fatalf("对象存储打开失败: %v", err)
+	if errors.Is(err, jetstream.ErrBucketNotFound) {
+		// 内嵌服务器中没有预先上传的对象，先写入测试数据
+		obj, err = putTestObject(ctx, js, bucket, "nats-cli", 1<<20)
 	}
-	log.Printf("对象存储 '%s' 打开成功\n", bucket)
-
-	fs, err := os.OpenFile("out/nats-cli-tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
-		log.Fatalf("打开文件失败: %v", err)
+		t.Fatalf("对象存储打开失败: %v", err)
 	}
+	log.Info("object store opened", LogKeyBucket, bucket)
 
-	obj_result, err := obj.Get(ctx, "nats-cli")
-	//obj_info, err := obj.PutFile(ctx, "nats-cli")
+	path := filepath.Join(t.TempDir(), "out", "nats-cli")
+	bar := NewProgressBar(os.Stdout)
+	bar.Logger = log.With(LogKeyBucket, bucket)
+	info, err := DownloadFile(ctx, obj, "nats-cli", path, WithFileProgress(bar.Func("nats-cli")))
 	if err != nil {
-		log.Fatalf("获取文件失败: %v", err)
+		t.Fatalf("下载文件失败: %v", err)
 	}
-	log.Printf("开始获取文件 'nats-cli' \n")
-	info, err := obj_result.Info()
+	log.Info("object downloaded", LogKeyBucket, bucket, LogKeyObject, info.Name, LogKeyPath, path, "size", info.Size, "modified", info.ModTime, "metadata", info.Metadata)
+	stat, err := os.Stat(path)
 	if err != nil {
-		log.Fatalf("获取文件信息失败: %v", err)
+		t.Fatalf("下载的文件不存在: %v", err)
 	}
-	log.Printf("File Name: %s", info.Name)
-	log.Printf("File Size: %d bytes", info.Size)
-	log.Printf("Modified: %v", info.ModTime)
-	for k, v := range info.Metadata {
-		log.Printf("Metadata %s: %s", k, v)
+	if uint64(stat.Size()) != info.Size {
+		t.Errorf("文件大小与对象大小不一致: %d != %d", stat.Size(), info.Size)
 	}
-	progressReader := &ProgressReader{
-		Reader: obj_result,
-		Total:  int64(info.Size),
-		OnProgress: func(readBytes int64, total int64) {
-			fmt.Printf("\r进度: %.2f%%", float64(readBytes)/float64(total)*100)
-		},
-	}
-	n, err := io.Copy(fs, progressReader)
+}
+
+// putTestObject 创建对象存储并写入指定大小的随机内容
+func putTestObject(ctx context.Context, js jetstream.JetStream, bucket, name string, size int) (jetstream.ObjectStore, error) {
+	obj, err := js.CreateOrUpdateObjectStore(ctx, jetstream.ObjectStoreConfig{Bucket: bucket})
 	if err != nil {
-		log.Fatalf("复制文件内容失败: %v", err)
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return nil, err
 	}
-	log.Printf("文件 '%s' 内容复制成功, 复制了 %d 字节\n", "nats-cli", n)
+	sum := sha256.Sum256(data)
+	_, err = obj.Put(ctx, jetstream.ObjectMeta{
+		Name:     name,
+		Metadata: map[string]string{"version": "1.0", MetaSHA256: hex.EncodeToString(sum[:])},
+	}, bytes.NewReader(data))
+	return obj, err
 }
@@ -1,33 +1,31 @@
 package nats_client
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
-	"fmt"
-	"io"
-	"log"
+	"crypto/rand"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go/jetstream"
 )
 
 func TestObjectPut(t *testing.T) {
 	bucket := "my_object_store"
-	nc, err := NewNATSConnect()
-	if err != nil {
-		log.Fatalf("连接失败: %v", err)
-	}
+	nc, _ := setupTestClusterConnection(t)
 	defer nc.Close()
+	log := testLogger(t)
 
 	// nc.QueueSubscribe(subject, "queue", func(msg *nats.Msg) {
 	// 	log.Printf("Received a message on subject %s: %s", msg.Subject, string(msg.Data))
 	// })
 
 	ctx := context.WithoutCancel(context.Background())
-	js, err := jetstream.NewWithDomain(nc, "hub")
+	js, err := testJetStream(nc)
 	if err != nil {
-		log.Fatalf("创建 JetStream 客户端失败: %v", err)
+		t.Fatalf("创建 JetStream 客户端失败: %v", err)
 	}
 
 	obj, err := js.CreateOrUpdateObjectStore(ctx, jetstream.ObjectStoreConfig{
@@ -36,50 +34,102 @@ func TestObjectPut(t *testing.T) {
 		Storage:  jetstream.FileStorage, // 持久化到磁盘
 	})
 	if err != nil {
-		log.Fatalf("创建或更新对象存储失败: %v", err)
+		t.Fatalf("创建或更新对象存储失败: %v", err)
 	}
-	log.Printf("对象存储 '%s' 创建成功\n", bucket)
-	fs, err := os.Open("nats-cli")
+	log.Info("object store ready", LogKeyBucket, bucket)
+	path := testUploadFile(t, "nats-cli", 4<<20)
+	bar := NewProgressBar(os.Stdout)
+	bar.Logger = log.With(LogKeyBucket, bucket)
+	obj_info, uploaded, err := UploadFile(ctx, obj, "nats-cli", path,
+		WithFileProgress(bar.Func("nats-cli")),
+		WithFileMeta(jetstream.ObjectMeta{
+			Description: "NATS CLI Tool",
+			Metadata:    map[string]string{"version": "1.0"},
+		}),
+	)
 	if err != nil {
-		log.Fatalf("打开文件失败: %v", err)
+		t.Fatalf("上传文件失败: %v", err)
 	}
-	stat, _ := fs.Stat()
-	log.Printf("打开文件成功: [%s]:%d", stat.Name(), stat.Size())
+	log.Info("object uploaded", LogKeyBucket, bucket, LogKeyObject, obj_info.Name, LogKeyPath, path, "size", obj_info.Size, "uploaded", uploaded, "sha256", obj_info.Metadata[MetaSHA256])
+}
 
-	// Calculate SHA-256 hash of the file
-	hash := sha256.New()
-	if _, err := io.Copy(hash, fs); err != nil {
-		log.Fatalf("计算文件哈希值失败: %v", err)
+// TestObjectPutNodeLoss 测试 R3 对象存储在 leader 节点停止后仍可读写
+func TestObjectPutNodeLoss(t *testing.T) {
+	if os.Getenv("NATS_TEST_URL") != "" {
+		t.Skip("需要停止节点，只在内嵌集群中运行")
 	}
-	sha256sum := fmt.Sprintf("%x", hash.Sum(nil))
-	log.Printf("文件的 SHA-256: %s", sha256sum)
+	nc, cluster := setupTestClusterConnection(t)
+	defer nc.Close()
 
-	// Reset file pointer to the beginning for the subsequent read
-	if _, err := fs.Seek(0, 0); err != nil {
-		log.Fatalf("重置文件指针失败: %v", err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	js, err := testJetStream(nc)
+	if err != nil {
+		t.Fatalf("创建 JetStream 客户端失败: %v", err)
+	}
+	obj, err := js.CreateObjectStore(ctx, jetstream.ObjectStoreConfig{
+		Bucket:   "durable_store",
+		Replicas: 3,
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		t.Fatalf("创建对象存储失败: %v", err)
+	}
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	if _, err := obj.PutBytes(ctx, "blob", data); err != nil {
+		t.Fatalf("上传失败: %v", err)
 	}
 
-	progressReader := &ProgressReader{
-		Reader: fs,
-		Total:  stat.Size(),
-		OnProgress: func(readBytes int64, total int64) {
-			fmt.Printf("\r进度: %.2f%%", float64(readBytes)/float64(total)*100)
-		},
+	// 对象存储底层流名为 OBJ_<bucket>
+	cluster.Kill(cluster.WaitForStreamLeader("OBJ_durable_store"))
+	cluster.WaitForStreamLeader("OBJ_durable_store")
+
+	// 客户端可能连接在被停止的节点上，重连完成之前的请求会失败，在期限内重试
+	var got []byte
+	if err := retryUntil(ctx, func() (err error) {
+		got, err = obj.GetBytes(ctx, "blob")
+		return err
+	}); err != nil {
+		t.Fatalf("停止节点后读取失败: %v", err)
 	}
-	obj_info, err := obj.Put(ctx,
-		jetstream.ObjectMeta{
-			Name:        "nats-cli",
-			Description: "NATS CLI Tool",
-			Metadata: map[string]string{
-				"version": "1.0",
-				"author":  "NATS Team",
-			},
-		},
-		progressReader,
-	)
-	//obj_info, err := obj.PutFile(ctx, "nats-cli")
-	if err != nil {
-		log.Fatalf("上传文件失败: %v", err)
+	if !bytes.Equal(got, data) {
+		t.Error("停止节点后读取的内容不一致")
+	}
+	if err := retryUntil(ctx, func() error {
+		_, err := obj.PutString(ctx, "after-loss", "ok")
+		return err
+	}); err != nil {
+		t.Errorf("停止节点后写入失败: %v", err)
+	}
+}
+
+// retryUntil 每 100ms 重试 f 直到成功或 ctx 结束，返回最后一次的错误
+func retryUntil(ctx context.Context, f func() error) error {
+	for {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// testUploadFile 返回待上传的文件：存在 name 时直接使用，否则生成指定大小的随机文件
+func testUploadFile(t *testing.T, name string, size int) string {
+	t.Helper()
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	data := make([]byte, size)
+	rand.Read(data)
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("生成测试文件失败: %v", err)
 	}
-	log.Printf("文件上传成功: %s, size: %d bytes\n", obj_info.Name, obj_info.Size)
+	return path
 }
@@ -0,0 +1,290 @@
+package nats_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// SyncAction 同步计划中的操作
+type SyncAction string
+
+const (
+	SyncUpload   SyncAction = "upload"   // 上传本地文件
+	SyncDownload SyncAction = "download" // 下载对象到本地
+	SyncDelete   SyncAction = "delete"   // 删除目标端多余的对象或文件
+)
+
+// SyncOp 同步计划中的一项操作
+type SyncOp struct {
+	Action SyncAction
+	Name   string // 对象名称，为 Prefix 加上以 / 分隔的相对路径
+	Path   string // 本地文件路径
+	Size   int64  // 需要传输的字节数，删除时为 0
+	Reason string // "new" 目标端不存在，"size" 大小不同，"digest" 内容不同，"extraneous" 源端不存在
+	Err    error  // 执行结果，演练或尚未执行时为 nil
+}
+
+// SyncConfig SyncUp 与 SyncDown 的配置
+type SyncConfig struct {
+	// Prefix 对象名称前缀，只同步以此开头的对象，例如 "builds/v1/"
+	Prefix string
+	// Delete 删除目标端多余的条目：SyncUp 删除本地不存在的对象，SyncDown 删除桶中不存在的本地文件
+	Delete bool
+	// DryRun 只生成计划，不执行
+	DryRun bool
+	// Concurrency 同时执行的操作数，默认 4
+	Concurrency int
+	// Tracker 每个传输计入 Tracker 的汇总进度
+	Tracker *ProgressTracker
+	// Limiter 所有传输共享的限速器
+	Limiter *RateLimiter
+}
+
+// SyncResult 同步计划与执行结果
+type SyncResult struct {
+	Ops       []SyncOp // 按对象名称排序
+	Unchanged int      // 两端内容相同的条目数
+}
+
+// SyncUp 把本地目录 dir 同步到对象存储，相对路径 (以 / 分隔) 加上 Prefix 作为对象名称。
+// 名称、大小相同时比较 SHA-256 (对象的 sha256 元数据，没有时为对象摘要)，只上传变化的文件。
+// 返回的错误合并了所有失败的操作，每项操作的结果记录在 SyncOp.Err 中
+func SyncUp(ctx context.Context, store jetstream.ObjectStore, dir string, cfg SyncConfig) (*SyncResult, error) {
+	// 本地目录不存在时不能当作空目录，否则 Delete 会删除所有对象
+	if st, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !st.IsDir() {
+		return nil, fmt.Errorf("nats: %s is not a directory", dir)
+	}
+	local, err := listLocal(dir, cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := listObjects(ctx, store, cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &SyncResult{}
+	digests := make(map[string][]byte) // 比较内容时已经算出的 SHA-256，上传时不再读取
+	for name, path := range local {
+		op := SyncOp{Action: SyncUpload, Name: name, Path: path}
+		reason, size, sum, err := compareFile(path, remote[name])
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" {
+			r.Unchanged++
+			continue
+		}
+		op.Size, op.Reason = size, reason
+		if sum != nil {
+			digests[name] = sum
+		}
+		r.Ops = append(r.Ops, op)
+	}
+	if cfg.Delete {
+		for name := range remote {
+			if _, ok := local[name]; !ok {
+				r.Ops = append(r.Ops, SyncOp{Action: SyncDelete, Name: name, Reason: "extraneous"})
+			}
+		}
+	}
+	return r, r.run(ctx, cfg, func(ctx context.Context, op SyncOp, opts []FileOption) error {
+		if op.Action == SyncDelete {
+			return store.Delete(ctx, op.Name)
+		}
+		opts = append(opts, withForceUpload())
+		if sum := digests[op.Name]; sum != nil {
+			opts = append(opts, withFileDigest(sum))
+		}
+		_, _, err := UploadFile(ctx, store, op.Name, op.Path, opts...)
+		return err
+	})
+}
+
+// SyncDown 把对象存储中以 Prefix 开头的对象同步到本地目录 dir，去掉 Prefix 后的名称作为相对路径。
+// 比较规则与 SyncUp 相同，只下载变化的对象，下载经过 DownloadFile 校验。
+// 名称会落到 dir 之外 (例如包含 ..) 的对象不会下载；多个对象的名称清理后是同一本地路径
+// (例如 a//b 与 a/b) 时只下载名称排在前面的一个。这两种情况以错误记录在对应的操作中
+func SyncDown(ctx context.Context, store jetstream.ObjectStore, dir string, cfg SyncConfig) (*SyncResult, error) {
+	local, err := listLocal(dir, cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := listObjects(ctx, store, cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	localPaths := make(map[string]bool, len(local))
+	for _, path := range local {
+		localPaths[path] = true
+	}
+	r := &SyncResult{}
+	claimed := make(map[string]string) // 本地路径 -> 写入它的对象名称
+	// 按名称顺序处理，多个对象落到同一本地路径时保留第一个
+	for _, name := range slices.Sorted(maps.Keys(remote)) {
+		info := remote[name]
+		op := SyncOp{Action: SyncDownload, Name: name, Size: int64(info.Size), Reason: "new"}
+		rel := strings.TrimPrefix(name, cfg.Prefix)
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			op.Err = fmt.Errorf("nats: object %s: name escapes %s", name, dir)
+			r.Ops = append(r.Ops, op)
+			continue
+		}
+		op.Path = filepath.Join(dir, filepath.FromSlash(rel))
+		if other, ok := claimed[op.Path]; ok {
+			op.Err = fmt.Errorf("nats: object %s: local path %s is also used by object %s", name, op.Path, other)
+			r.Ops = append(r.Ops, op)
+			continue
+		}
+		claimed[op.Path] = name
+		if localPaths[op.Path] {
+			reason, _, _, err := compareFile(op.Path, info)
+			if err != nil {
+				return nil, err
+			}
+			if reason == "" {
+				r.Unchanged++
+				continue
+			}
+			op.Reason = reason
+		}
+		r.Ops = append(r.Ops, op)
+	}
+	if cfg.Delete {
+		for name, path := range local {
+			if _, ok := claimed[path]; !ok {
+				r.Ops = append(r.Ops, SyncOp{Action: SyncDelete, Name: name, Path: path, Reason: "extraneous"})
+			}
+		}
+	}
+	return r, r.run(ctx, cfg, func(ctx context.Context, op SyncOp, opts []FileOption) error {
+		if op.Action == SyncDelete {
+			return os.Remove(op.Path)
+		}
+		_, err := DownloadFile(ctx, store, op.Name, op.Path, opts...)
+		return err
+	})
+}
+
+// run 按名称排序计划，非演练时以 cfg.Concurrency 并发执行，返回合并的错误
+func (r *SyncResult) run(ctx context.Context, cfg SyncConfig, exec func(context.Context, SyncOp, []FileOption) error) error {
+	slices.SortFunc(r.Ops, func(a, b SyncOp) int { return strings.Compare(a.Name, b.Name) })
+	if !cfg.DryRun {
+		concurrency := cfg.Concurrency
+		if concurrency <= 0 {
+			concurrency = 4
+		}
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i := range r.Ops {
+			op := &r.Ops[i]
+			if op.Err != nil {
+				continue
+			}
+			var opts []FileOption
+			if cfg.Limiter != nil {
+				opts = append(opts, WithFileRateLimit(cfg.Limiter))
+			}
+			if cfg.Tracker != nil && op.Action != SyncDelete {
+				opts = append(opts, WithFileTransfer(cfg.Tracker.Add(op.Name, op.Size)))
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+					op.Err = exec(ctx, *op, opts)
+				case <-ctx.Done():
+					op.Err = ctx.Err()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	var errs []error
+	for _, op := range r.Ops {
+		if op.Err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", op.Action, op.Name, op.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// listLocal 返回 dir 下所有普通文件，键为加上 prefix 的对象名称。dir 不存在时返回空
+func listLocal(dir, prefix string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[prefix+filepath.ToSlash(rel)] = path
+		return nil
+	})
+	return files, err
+}
+
+// listObjects 返回以 prefix 开头的对象，不包括链接
+func listObjects(ctx context.Context, store jetstream.ObjectStore, prefix string) (map[string]*jetstream.ObjectInfo, error) {
+	list, err := store.List(ctx)
+	if err != nil && !errors.Is(err, jetstream.ErrNoObjectsFound) {
+		return nil, err
+	}
+	objects := make(map[string]*jetstream.ObjectInfo)
+	for _, info := range list {
+		if strings.HasPrefix(info.Name, prefix) && (info.Opts == nil || info.Opts.Link == nil) {
+			objects[info.Name] = info
+		}
+	}
+	return objects, nil
+}
+
+// compareFile 比较本地文件与对象，返回需要传输的原因 (内容相同时为空)、文件大小，
+// 以及大小相同时计算的文件 SHA-256 (大小不同时不读取内容，为 nil)
+func compareFile(path string, info *jetstream.ObjectInfo) (reason string, size int64, sum []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return "", 0, nil, err
+	}
+	switch {
+	case info == nil:
+		return "new", st.Size(), nil, nil
+	case info.Size != uint64(st.Size()):
+		return "size", st.Size(), nil, nil
+	}
+	if sum, err = hashFile(f); err != nil {
+		return "", 0, nil, err
+	}
+	if !sameContent(info, sum) {
+		return "digest", st.Size(), sum, nil
+	}
+	return "", st.Size(), sum, nil
+}
@@ -0,0 +1,198 @@
+package nats_client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree 在 dir 下写入文件，键为以 / 分隔的相对路径
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// planOf 把同步计划转换为 名称 -> 操作:原因
+func planOf(r *SyncResult) map[string]string {
+	m := make(map[string]string)
+	for _, op := range r.Ops {
+		m[op.Name] = string(op.Action) + ":" + op.Reason
+	}
+	return m
+}
+
+func assertPlan(t *testing.T, r *SyncResult, want map[string]string, unchanged int) {
+	t.Helper()
+	got := planOf(r)
+	if len(got) != len(want) || r.Unchanged != unchanged {
+		t.Fatalf("计划不匹配: %v (unchanged %d)", got, r.Unchanged)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s 的操作不匹配: %s != %s", name, got[name], w)
+		}
+	}
+}
+
+// TestSyncUp 测试演练、只上传变化的文件、删除多余的对象，不影响前缀之外的对象
+func TestSyncUp(t *testing.T) {
+	_, obj := testObjectStore(t, "builds")
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"app.bin":          "binary-v1",
+		"lib/core.so":      "core-v1",
+		"lib/plugins/x.so": "plugin",
+		"README":           "readme",
+	})
+	if _, err := obj.PutString(ctx, "other/keep", "keep"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := SyncConfig{Prefix: "build/", Delete: true, DryRun: true}
+
+	r, err := SyncUp(ctx, obj, dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, r, map[string]string{
+		"build/app.bin": "upload:new", "build/lib/core.so": "upload:new", "build/lib/plugins/x.so": "upload:new", "build/README": "upload:new",
+	}, 0)
+	if r.Ops[0].Name != "build/README" || r.Ops[0].Size != 6 {
+		t.Errorf("计划应按名称排序并包含大小: %+v", r.Ops[0])
+	}
+	if _, err := obj.GetInfo(ctx, "build/app.bin"); err == nil {
+		t.Fatal("演练不应上传")
+	}
+
+	cfg.DryRun = false
+	if _, err := SyncUp(ctx, obj, dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := obj.GetString(ctx, "build/lib/plugins/x.so"); got != "plugin" {
+		t.Fatalf("对象内容不匹配: %q", got)
+	}
+
+	// 大小变化、大小相同内容变化、新增、删除
+	writeTree(t, dir, map[string]string{"app.bin": "binary-v22", "lib/core.so": "core-v2", "lib/new.so": "new"})
+	os.RemoveAll(filepath.Join(dir, "lib", "plugins"))
+	tk := NewProgressTracker(nil)
+	cfg.Concurrency, cfg.Tracker = 2, tk
+	r, err = SyncUp(ctx, obj, dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, r, map[string]string{
+		"build/app.bin": "upload:size", "build/lib/core.so": "upload:digest", "build/lib/new.so": "upload:new", "build/lib/plugins/x.so": "delete:extraneous",
+	}, 1)
+	if p := tk.Progress(); p.Succeeded != 3 || p.Bytes != 10+7+3 {
+		t.Errorf("汇总进度不匹配: %+v", p)
+	}
+	if got, _ := obj.GetString(ctx, "build/lib/core.so"); got != "core-v2" {
+		t.Errorf("内容变化的文件未上传: %q", got)
+	}
+	if _, err := obj.GetInfo(ctx, "build/lib/plugins/x.so"); err == nil {
+		t.Error("多余的对象应被删除")
+	}
+	if _, err := obj.GetInfo(ctx, "other/keep"); err != nil {
+		t.Errorf("前缀之外的对象不应被删除: %v", err)
+	}
+
+	r, err = SyncUp(ctx, obj, dir, cfg)
+	if err != nil || len(r.Ops) != 0 || r.Unchanged != 4 {
+		t.Errorf("没有变化时不应有操作: %v %+v", err, r)
+	}
+	if _, err := SyncUp(ctx, obj, filepath.Join(dir, "missing"), cfg); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("本地目录不存在时应返回错误: %v", err)
+	}
+}
+
+// TestSyncDown 测试下载变化的对象、删除多余的本地文件，以及拒绝落到目录之外的对象名称
+func TestSyncDown(t *testing.T) {
+	_, obj := testObjectStore(t, "builds")
+	ctx := context.Background()
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"app.bin": "binary", "lib/core.so": "core", "lib/util.so": "util"})
+	if _, err := SyncUp(ctx, obj, src, SyncConfig{Prefix: "build/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "out")
+	cfg := SyncConfig{Prefix: "build/", Delete: true}
+	r, err := SyncDown(ctx, obj, dst, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, r, map[string]string{"build/app.bin": "download:new", "build/lib/core.so": "download:new", "build/lib/util.so": "download:new"}, 0)
+	if got, _ := os.ReadFile(filepath.Join(dst, "lib", "core.so")); string(got) != "core" {
+		t.Fatalf("下载的内容不匹配: %q", got)
+	}
+
+	writeTree(t, dst, map[string]string{"lib/core.so": "CORE", "lib/util.so": "utility", "stale.txt": "stale"})
+	r, err = SyncDown(ctx, obj, dst, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, r, map[string]string{"build/lib/core.so": "download:digest", "build/lib/util.so": "download:size", "build/stale.txt": "delete:extraneous"}, 1)
+	for rel, want := range map[string]string{"app.bin": "binary", "lib/core.so": "core", "lib/util.so": "util"} {
+		if got, _ := os.ReadFile(filepath.Join(dst, filepath.FromSlash(rel))); !bytes.Equal(got, []byte(want)) {
+			t.Errorf("%s 未恢复: %q", rel, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "stale.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("多余的本地文件应被删除")
+	}
+
+	if _, err := obj.PutString(ctx, "build/../evil", "x"); err != nil {
+		t.Fatal(err)
+	}
+	r, err = SyncDown(ctx, obj, dst, cfg)
+	if err == nil || len(r.Ops) != 1 || r.Ops[0].Err == nil {
+		t.Fatalf("落到目录之外的对象应返回错误: %v %+v", err, r)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dst), "evil")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("不应写入目录之外")
+	}
+}
+
+// TestSyncDownDuplicatePath 测试多个对象落到同一本地路径时只下载第一个，其余以错误记录，也不会删除该文件
+func TestSyncDownDuplicatePath(t *testing.T) {
+	_, obj := testObjectStore(t, "builds")
+	ctx := context.Background()
+	for name, data := range map[string]string{"lib/core.so": "core", "lib//core.so": "other", "lib/./core.so": "dot"} {
+		if _, err := obj.PutString(ctx, name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst := t.TempDir()
+	r, err := SyncDown(ctx, obj, dst, SyncConfig{Delete: true})
+	if err == nil {
+		t.Fatal("同一路径的多个对象应返回错误")
+	}
+	failed := 0
+	for _, op := range r.Ops {
+		if op.Action != SyncDownload {
+			t.Errorf("不应删除被对象使用的本地路径: %+v", op)
+		}
+		if op.Err != nil {
+			failed++
+		}
+	}
+	if len(r.Ops) != 3 || failed != 2 {
+		t.Fatalf("计划不匹配: %+v", r.Ops)
+	}
+	// 名称排在最前面的 lib/./core.so 被下载
+	if got, _ := os.ReadFile(filepath.Join(dst, "lib", "core.so")); string(got) != "dot" {
+		t.Errorf("下载的内容不匹配: %q", got)
+	}
+}
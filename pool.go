@@ -0,0 +1,202 @@
+package nats_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Purpose 连接的用途，不同用途的流量使用独立的连接，互不阻塞
+type Purpose string
+
+const (
+	PurposeBulk     Purpose = "bulk"     // 对象存储等大块传输
+	PurposeControl  Purpose = "control"  // 管理与 JetStream API 调用
+	PurposeRealtime Purpose = "realtime" // 对延迟敏感的请求/响应
+)
+
+// DefaultPoolSizes 每种用途一个连接
+var DefaultPoolSizes = map[Purpose]int{
+	PurposeBulk:     1,
+	PurposeControl:  1,
+	PurposeRealtime: 1,
+}
+
+// Pool 按用途分组的一组连接，所有成员共享同一份配置
+type Pool struct {
+	groups   map[Purpose]*poolGroup
+	purposes []Purpose // 按名称排序，保证 Conns 的顺序稳定
+	all      poolGroup
+}
+
+// poolGroup 一组连接与轮询计数
+type poolGroup struct {
+	conns []*nats.Conn
+	next  atomic.Uint64
+}
+
+// pick 轮询选择一个连接，优先跳过正在重连的成员
+func (g *poolGroup) pick() *nats.Conn {
+	n := uint64(len(g.conns))
+	if n == 0 {
+		return nil
+	}
+	start := g.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		if nc := g.conns[(start+i)%n]; nc.IsConnected() {
+			return nc
+		}
+	}
+	return g.conns[start%n]
+}
+
+// NewPool 使用选项构建配置并建立连接池，sizes 为空时使用 DefaultPoolSizes
+func NewPool(sizes map[Purpose]int, opts ...Option) (*Pool, error) {
+	cfg, err := NewConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.ConnectPool(sizes)
+}
+
+// ConnectPool 按 sizes 为每种用途建立连接，连接名称为 <Name>-<用途>-<序号>。
+// 任一连接失败时关闭已建立的连接并返回错误
+func (c *Config) ConnectPool(sizes map[Purpose]int) (*Pool, error) {
+	if len(sizes) == 0 {
+		sizes = DefaultPoolSizes
+	}
+	name := c.Name
+	if name == "" {
+		name = DefaultName
+	}
+	p := &Pool{groups: make(map[Purpose]*poolGroup)}
+	for purpose, n := range sizes {
+		if n < 1 {
+			return nil, fmt.Errorf("nats: pool %s: invalid size %d", purpose, n)
+		}
+		p.purposes = append(p.purposes, purpose)
+	}
+	sort.Slice(p.purposes, func(i, j int) bool { return p.purposes[i] < p.purposes[j] })
+
+	for _, purpose := range p.purposes {
+		g := &poolGroup{}
+		p.groups[purpose] = g
+		for i := 0; i < sizes[purpose]; i++ {
+			member := *c
+			member.Name = fmt.Sprintf("%s-%s-%d", name, purpose, i)
+			nc, err := member.Connect()
+			if err != nil {
+				p.Close()
+				return nil, fmt.Errorf("nats: pool %s: %w", member.Name, err)
+			}
+			g.conns = append(g.conns, nc)
+			p.all.conns = append(p.all.conns, nc)
+		}
+	}
+	return p, nil
+}
+
+// Get 返回指定用途的连接，同一用途有多个连接时轮询；
+// 没有该用途的连接时在全部连接中轮询
+func (p *Pool) Get(purpose Purpose) *nats.Conn {
+	if g, ok := p.groups[purpose]; ok {
+		return g.pick()
+	}
+	return p.all.pick()
+}
+
+// Next 在全部连接中轮询
+func (p *Pool) Next() *nats.Conn {
+	return p.all.pick()
+}
+
+// Conns 返回全部连接
+func (p *Pool) Conns() []*nats.Conn {
+	return append([]*nats.Conn(nil), p.all.conns...)
+}
+
+// PoolStats 连接池的统计信息
+type PoolStats struct {
+	nats.Statistics                             // 全部连接的合计
+	Conns           int                         // 连接总数
+	Connected       int                         // 当前处于连接状态的数量
+	Purposes        map[Purpose]nats.Statistics // 按用途合计
+}
+
+// Stats 汇总所有连接的收发与重连统计
+func (p *Pool) Stats() PoolStats {
+	s := PoolStats{Conns: len(p.all.conns), Purposes: make(map[Purpose]nats.Statistics)}
+	for purpose, g := range p.groups {
+		var sum nats.Statistics
+		for _, nc := range g.conns {
+			addStats(&sum, nc.Stats())
+			if nc.IsConnected() {
+				s.Connected++
+			}
+		}
+		s.Purposes[purpose] = sum
+		addStats(&s.Statistics, sum)
+	}
+	return s
+}
+
+func addStats(dst *nats.Statistics, src nats.Statistics) {
+	dst.InMsgs += src.InMsgs
+	dst.OutMsgs += src.OutMsgs
+	dst.InBytes += src.InBytes
+	dst.OutBytes += src.OutBytes
+	dst.Reconnects += src.Reconnects
+}
+
+// Drain 并行排空所有连接：停止订阅、处理完已收到的消息、发送缓冲区中的数据后关闭。
+// ctx 结束时强制关闭尚未完成的连接并返回 ctx 的错误
+func (p *Pool) Drain(ctx context.Context) error {
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range p.all.conns {
+		wg.Add(1)
+		go func(nc *nats.Conn) {
+			defer wg.Done()
+			if err := drainConn(ctx, nc); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", nc.Opts.Name, err))
+				mu.Unlock()
+			}
+		}(nc)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// drainConn 排空单个连接并等待其关闭
+func drainConn(ctx context.Context, nc *nats.Conn) error {
+	if err := nc.Drain(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+		nc.Close()
+		return err
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for !nc.IsClosed() {
+		select {
+		case <-ctx.Done():
+			nc.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Close 立即关闭所有连接
+func (p *Pool) Close() {
+	for _, nc := range p.all.conns {
+		nc.Close()
+	}
+}
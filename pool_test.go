@@ -0,0 +1,112 @@
+package nats_client
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// TestPool 测试按用途获取连接、轮询与统计
+func TestPool(t *testing.T) {
+	s := natstest.RunServer(t)
+	pool, err := NewPool(map[Purpose]int{PurposeBulk: 2, PurposeRealtime: 1},
+		WithURL(s.ClientURL()), WithName("svc"), WithDialer(nil))
+	if err != nil {
+		t.Fatalf("创建连接池失败: %v", err)
+	}
+	defer pool.Close()
+
+	if n := len(pool.Conns()); n != 3 {
+		t.Fatalf("连接数量不匹配: %d", n)
+	}
+	if name := pool.Get(PurposeRealtime).Opts.Name; name != "svc-realtime-0" {
+		t.Errorf("连接名称不匹配: %q", name)
+	}
+	a, b := pool.Get(PurposeBulk), pool.Get(PurposeBulk)
+	if a == b || pool.Get(PurposeBulk) != a {
+		t.Error("同一用途的连接应轮询")
+	}
+	if pool.Get(PurposeControl) == nil {
+		t.Error("没有该用途的连接时应在全部连接中轮询")
+	}
+
+	// 关闭一个 bulk 连接后轮询跳过它
+	a.Close()
+	for i := 0; i < 4; i++ {
+		if pool.Get(PurposeBulk) != b {
+			t.Fatal("轮询应跳过已断开的连接")
+		}
+	}
+
+	rt := pool.Get(PurposeRealtime)
+	sub, err := rt.SubscribeSync("pool.echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.Flush()
+	if err := b.Publish("pool.echo", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.NextMsg(2 * time.Second); err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+
+	st := pool.Stats()
+	if st.Conns != 3 || st.Connected != 2 {
+		t.Errorf("连接状态统计不匹配: %+v", st)
+	}
+	if st.Purposes[PurposeBulk].OutMsgs != 1 || st.Purposes[PurposeRealtime].InMsgs != 1 || st.OutMsgs != 1 {
+		t.Errorf("消息统计不匹配: %+v", st)
+	}
+}
+
+// TestPoolDrain 测试排空时已收到的消息处理完后再关闭
+func TestPoolDrain(t *testing.T) {
+	s := natstest.RunServer(t)
+	pool, err := NewPool(nil, WithURL(s.ClientURL()), WithDialer(nil))
+	if err != nil {
+		t.Fatalf("创建连接池失败: %v", err)
+	}
+
+	var handled atomic.Int32
+	if _, err := pool.Get(PurposeControl).Subscribe("pool.work", func(*nats.Msg) {
+		time.Sleep(5 * time.Millisecond)
+		handled.Add(1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pub := pool.Get(PurposeBulk)
+	for i := 0; i < 20; i++ {
+		pub.Publish("pool.work", nil)
+	}
+	pub.Flush()
+	pool.Get(PurposeControl).Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatalf("排空失败: %v", err)
+	}
+	for _, nc := range pool.Conns() {
+		if !nc.IsClosed() {
+			t.Errorf("%s 未关闭", nc.Opts.Name)
+		}
+	}
+	if n := handled.Load(); n != 20 {
+		t.Errorf("排空前应处理完全部消息: %d", n)
+	}
+}
+
+// TestPoolConnectError 测试部分连接失败时关闭已建立的连接
+func TestPoolConnectError(t *testing.T) {
+	if _, err := NewPool(map[Purpose]int{PurposeBulk: 0}); err == nil {
+		t.Error("连接数量为 0 时期望报错")
+	}
+	if _, err := NewPool(nil, WithURL("nats://127.0.0.1:1"), WithDialer(nil), WithReconnect(0, 0)); err == nil {
+		t.Error("服务器不可达时期望报错")
+	}
+}
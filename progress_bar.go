@@ -0,0 +1,287 @@
+package nats_client
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// DefaultProgressLogInterval 非终端输出时两次进度日志的最短间隔
+const DefaultProgressLogInterval = 5 * time.Second
+
+// ProgressBar 把进度回调渲染为终端进度条，显示字节数、速率与剩余时间。
+// 多个传输各占一行，每次回调原地重绘。输出不是终端时改为按 LogInterval 记录进度日志，
+// 传输结束时总会记录一次。可以并发调用
+type ProgressBar struct {
+	Out io.Writer
+	// TTY 为 true 时使用 ANSI 控制符重绘进度条，NewProgressBar 根据 Out 检测
+	TTY bool
+	// Width 进度条宽度 (字符数)，默认 30
+	Width int
+	// Logger 非终端输出时记录进度，默认 slog.Default()
+	Logger *slog.Logger
+	// LogInterval 非终端输出时两次进度日志的最短间隔，默认 DefaultProgressLogInterval
+	LogInterval time.Duration
+
+	mu      sync.Mutex
+	rows    []progressRow // Update 登记的传输，按第一次回调的顺序
+	lines   int           // 上次绘制的行数
+	lastLog map[string]time.Time
+	states  map[string]TransferState // Tracker 已记录的每项状态
+}
+
+type progressRow struct {
+	name  string
+	state TransferState
+	p     Progress
+}
+
+// NewProgressBar 创建输出到 out 的进度条，out 是终端时绘制进度条，否则记录日志
+func NewProgressBar(out io.Writer) *ProgressBar {
+	return &ProgressBar{Out: out, TTY: isTerminal(out)}
+}
+
+// isTerminal 判断 w 是否为终端。/dev/null 等字符设备不是终端
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// Func 返回名为 name 的传输的进度回调，用作 ProgressReader / ProgressWriter 的 OnProgress
+func (b *ProgressBar) Func(name string) func(Progress) {
+	return func(p Progress) { b.Update(name, p) }
+}
+
+// Update 更新名为 name 的传输的进度。所有传输都结束后进度条保留在屏幕上，
+// 之后的传输从新的一行开始绘制
+func (b *ProgressBar) Update(name string, p Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	row := progressRow{name: name, state: progressState(p), p: p}
+	if !b.TTY {
+		b.logRow(row, false)
+		return
+	}
+	found, done := false, true
+	for i := range b.rows {
+		if b.rows[i].name == name {
+			b.rows[i], found = row, true
+		}
+		done = done && b.rows[i].p.Done
+	}
+	if !found {
+		b.rows = append(b.rows, row)
+		done = done && p.Done
+	}
+	b.draw(b.rows)
+	if done {
+		b.rows, b.lines = nil, 0
+	}
+}
+
+// Tracker 渲染 ProgressTracker 的汇总进度，用作 ProgressTracker 的 OnProgress。
+// 终端上每项一行，最后一行为汇总；非终端时记录每项的结束与周期性的汇总日志
+func (b *ProgressBar) Tracker(tp TrackerProgress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := progressRow{
+		name:  fmt.Sprintf("total %d/%d", tp.Succeeded+tp.Failed, len(tp.Items)),
+		state: TransferRunning,
+		p:     tp.Progress,
+	}
+	if tp.Done {
+		total.state = TransferDone
+		if tp.Failed > 0 {
+			total.state = TransferFailed
+		}
+	}
+	if !b.TTY {
+		if b.states == nil {
+			b.states = make(map[string]TransferState)
+		}
+		for _, s := range tp.Items {
+			if b.states[s.Name] != s.State && s.Done {
+				b.logRow(progressRow{name: s.Name, state: s.State, p: s.Progress}, true)
+			}
+			b.states[s.Name] = s.State
+		}
+		b.logTotal(total, tp)
+		if tp.Done {
+			b.states = nil
+		}
+		return
+	}
+	rows := make([]progressRow, 0, len(tp.Items)+1)
+	for _, s := range tp.Items {
+		rows = append(rows, progressRow{name: s.Name, state: s.State, p: s.Progress})
+	}
+	b.draw(append(rows, total))
+	if tp.Done {
+		b.lines = 0
+	}
+}
+
+// progressState 根据进度推断单个传输的状态
+func progressState(p Progress) TransferState {
+	switch {
+	case p.Done && p.Err != nil:
+		return TransferFailed
+	case p.Done:
+		return TransferDone
+	case p.Bytes > 0:
+		return TransferRunning
+	}
+	return TransferQueued
+}
+
+// draw 把光标移回上次绘制的第一行并重绘所有行
+func (b *ProgressBar) draw(rows []progressRow) {
+	nameWidth := 0
+	for _, r := range rows {
+		nameWidth = max(nameWidth, len([]rune(r.name)))
+	}
+	nameWidth = min(nameWidth, 32)
+	var buf strings.Builder
+	if b.lines > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dA", b.lines)
+	}
+	for _, r := range rows {
+		buf.WriteString("\r\x1b[2K")
+		buf.WriteString(b.line(r, nameWidth))
+		buf.WriteByte('\n')
+	}
+	b.lines = len(rows)
+	io.WriteString(b.Out, buf.String())
+}
+
+// line 格式化一行进度，例如
+//
+//	nats-cli [==========>         ]  52.3%  2.1 MiB/4.0 MiB  1.5 MiB/s  ETA 1s
+func (b *ProgressBar) line(r progressRow, nameWidth int) string {
+	width := b.Width
+	if width <= 0 {
+		width = 30
+	}
+	name := []rune(r.name)
+	if len(name) > nameWidth {
+		name = append(name[:nameWidth-1], '~')
+	}
+	var bar, percent string
+	if r.p.Total > 0 {
+		fill := min(int(r.p.Percent()/100*float64(width)), width)
+		bar = strings.Repeat("=", fill)
+		if fill < width && r.state != TransferDone {
+			bar += ">"
+		}
+		percent = fmt.Sprintf("%5.1f%%", min(r.p.Percent(), 100))
+	} else {
+		percent = "  --.-%"
+	}
+	if r.state == TransferDone {
+		bar = strings.Repeat("=", width)
+	}
+	s := fmt.Sprintf("%-*s [%-*s] %s  %s", nameWidth, string(name), width, bar, percent, formatBytes(r.p.Bytes))
+	if r.p.Total > 0 {
+		s += "/" + formatBytes(r.p.Total)
+	}
+	switch r.state {
+	case TransferQueued:
+		return s + "  queued"
+	case TransferDone:
+		return s + fmt.Sprintf("  %s/s  done in %s", formatBytes(int64(r.p.Rate)), r.p.Elapsed.Round(time.Millisecond))
+	case TransferFailed:
+		if r.p.Err != nil {
+			return s + "  failed: " + r.p.Err.Error()
+		}
+		return s + "  failed"
+	}
+	s += fmt.Sprintf("  %s/s", formatBytes(int64(r.p.Rate)))
+	if r.p.ETA > 0 {
+		s += "  ETA " + r.p.ETA.Round(time.Second).String()
+	}
+	return s
+}
+
+// logRow 记录一个传输的进度日志，未结束时按 LogInterval 节流，force 时不节流
+func (b *ProgressBar) logRow(r progressRow, force bool) {
+	if !force && !b.logDue(r.name, r.p.Done) {
+		return
+	}
+	l := logger(b.Logger)
+	attrs := append([]any{LogKeyTransfer, r.name, "state", string(r.state)}, progressAttrs(r.p)...)
+	switch r.state {
+	case TransferFailed:
+		l.Warn("transfer failed", append(attrs, LogKeyError, r.p.Err)...)
+	case TransferDone:
+		l.Info("transfer finished", attrs...)
+	default:
+		l.Info("transfer progress", attrs...)
+	}
+}
+
+// logTotal 记录汇总进度日志，按 LogInterval 节流，全部结束时总会记录
+func (b *ProgressBar) logTotal(r progressRow, tp TrackerProgress) {
+	if !b.logDue("", tp.Done) {
+		return
+	}
+	msg := "transfers progress"
+	if tp.Done {
+		msg = "transfers finished"
+	}
+	attrs := append([]any{"queued", tp.Queued, "running", tp.Running, "done", tp.Succeeded, "failed", tp.Failed}, progressAttrs(r.p)...)
+	logger(b.Logger).Info(msg, attrs...)
+}
+
+// logDue 判断名为 name 的日志是否到期，done 时总是到期并清除记录
+func (b *ProgressBar) logDue(name string, done bool) bool {
+	if b.lastLog == nil {
+		b.lastLog = make(map[string]time.Time)
+	}
+	if done {
+		delete(b.lastLog, name)
+		return true
+	}
+	interval := b.LogInterval
+	if interval <= 0 {
+		interval = DefaultProgressLogInterval
+	}
+	now := time.Now()
+	if last, ok := b.lastLog[name]; ok && now.Sub(last) < interval {
+		return false
+	}
+	b.lastLog[name] = now
+	return true
+}
+
+// progressAttrs 进度日志的字段
+func progressAttrs(p Progress) []any {
+	attrs := []any{"bytes", p.Bytes}
+	if p.Total > 0 {
+		attrs = append(attrs, "total", p.Total, "percent", fmt.Sprintf("%.1f", p.Percent()))
+	}
+	attrs = append(attrs, "rate", formatBytes(int64(p.Rate))+"/s", "elapsed", p.Elapsed.Round(time.Millisecond))
+	if p.ETA > 0 {
+		attrs = append(attrs, "eta", p.ETA.Round(time.Millisecond))
+	}
+	return attrs
+}
+
+// formatBytes 以二进制单位格式化字节数，例如 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	f, i := float64(n)/unit, 0
+	for f >= unit && i < 4 {
+		f /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", f, "KMGTP"[i])
+}
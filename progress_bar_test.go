@@ -0,0 +1,95 @@
+package nats_client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestProgressBarTTY 测试终端上按行原地重绘多个传输的进度条
+func TestProgressBarTTY(t *testing.T) {
+	var out bytes.Buffer
+	bar := &ProgressBar{Out: &out, TTY: true, Width: 10}
+	bar.Update("a", Progress{Bytes: 512, Total: 1024, Rate: 2048, ETA: 1e9})
+	if s := out.String(); !strings.Contains(s, "a [=====>    ]  50.0%  512 B/1.0 KiB  2.0 KiB/s  ETA 1s") || strings.Contains(s, "\x1b[1A") {
+		t.Fatalf("第一行不匹配: %q", s)
+	}
+	out.Reset()
+	bar.Update("bb", Progress{Bytes: 10})
+	if s := out.String(); !strings.HasPrefix(s, "\x1b[1A") || strings.Count(s, "\n") != 2 || !strings.Contains(s, "--.-%") {
+		t.Fatalf("第二个传输应重绘两行: %q", s)
+	}
+	out.Reset()
+	bar.Update("a", Progress{Bytes: 1024, Total: 1024, Done: true})
+	bar.Update("bb", Progress{Bytes: 10, Done: true, Err: errors.New("boom")})
+	if s := out.String(); !strings.Contains(s, "[==========] 100.0%") || !strings.Contains(s, "failed: boom") {
+		t.Fatalf("结束行不匹配: %q", s)
+	}
+	// 全部结束后从新的一行开始
+	out.Reset()
+	bar.Update("c", Progress{})
+	if s := out.String(); !strings.HasPrefix(s, "\r\x1b[2K") || !strings.Contains(s, "queued") {
+		t.Errorf("新的传输不应覆盖已结束的行: %q", s)
+	}
+}
+
+// TestProgressBarNotTerminal 测试 /dev/null 等非终端的字符设备按日志输出
+func TestProgressBarNotTerminal(t *testing.T) {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	if NewProgressBar(f).TTY {
+		t.Errorf("%s 不是终端", os.DevNull)
+	}
+}
+
+// TestProgressBarTracker 测试汇总进度在终端上每项一行，非终端时记录每项结束与汇总日志
+func TestProgressBarTracker(t *testing.T) {
+	var out, logs bytes.Buffer
+	tty := &ProgressBar{Out: &out, TTY: true}
+	plain := NewProgressBar(&out) // bytes.Buffer 不是终端
+	plain.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	if plain.TTY {
+		t.Fatal("bytes.Buffer 不应识别为终端")
+	}
+	tk := NewProgressTracker(func(p TrackerProgress) {
+		tty.Tracker(p)
+		plain.Tracker(p)
+	})
+	ctx := context.Background()
+	ok, bad := tk.Add("ok.bin", 100), tk.Add("bad.bin", 100)
+	io.Copy(io.Discard, ok.Reader(ctx, bytes.NewReader(make([]byte, 100))))
+	bad.Done(errors.New("store unavailable"))
+
+	s := out.String()
+	last := s[strings.LastIndex(s, "\x1b[3A"):]
+	for _, want := range []string{"ok.bin", "done in", "bad.bin", "failed: store unavailable", "total 2/2"} {
+		if !strings.Contains(last, want) {
+			t.Errorf("最后一次绘制缺少 %q: %q", want, last)
+		}
+	}
+	l := logs.String()
+	for _, want := range []string{"msg=\"transfer finished\" transfer=ok.bin", "msg=\"transfer failed\" transfer=bad.bin", "msg=\"transfers finished\"", "done=1 failed=1"} {
+		if !strings.Contains(l, want) {
+			t.Errorf("日志缺少 %q: %s", want, l)
+		}
+	}
+	if strings.Count(l, "transfer finished") != 1 {
+		t.Errorf("每项结束只记录一次: %s", l)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 4 << 20: "4.0 MiB", 3 << 40: "3.0 TiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, 期望 %s", n, got, want)
+		}
+	}
+}
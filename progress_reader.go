@@ -1,19 +1,174 @@
 package nats_client
 
-import "io"
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
+// DefaultProgressInterval 未设置 Interval 与 Step 时两次进度回调的最短间隔
+const DefaultProgressInterval = 200 * time.Millisecond
+
+// Progress 某一时刻的传输进度
+type Progress struct {
+	Bytes   int64         // 已传输字节数
+	Total   int64         // 总大小，未知时为 0
+	Elapsed time.Duration // 从第一次读写开始经过的时间，结束后截至结束时间
+	Rate    float64       // 平均速率，字节/秒
+	ETA     time.Duration // 预计剩余时间，总大小或速率未知时为 0
+	Done    bool          // 传输已结束 (读到 EOF、达到总大小或出错)
+	Err     error         // 导致传输结束的错误，正常结束时为 nil
+}
+
+// Percent 返回完成百分比，总大小未知时返回 0
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Bytes) / float64(p.Total) * 100
+}
+
+// progress 读写进度的计数与回调节流，计数可以并发读取
+type progress struct {
+	n     atomic.Int64
+	start atomic.Int64 // 第一次读写的时间 (UnixNano)，0 表示尚未开始
+	end   atomic.Int64 // 结束的时间 (UnixNano)，0 表示尚未结束
+	done  atomic.Bool
+
+	// hook 每次读写与结束时调用，不节流，供 ProgressTracker 汇总
+	hook func(n int, finished bool, err error)
+
+	mu          sync.Mutex
+	lastTime    time.Time
+	lastPercent float64
+}
+
+// begin 记录开始时间
+func (p *progress) begin() {
+	if p.start.Load() == 0 {
+		p.start.CompareAndSwap(0, time.Now().UnixNano())
+	}
+}
+
+// finish 标记结束并记录结束时间，之后的进度按结束时间计算耗时与速率。返回是否为第一次结束
+func (p *progress) finish() bool {
+	if !p.done.CompareAndSwap(false, true) {
+		return false
+	}
+	p.end.Store(time.Now().UnixNano())
+	return true
+}
+
+// snapshot 计算当前进度，已经结束时截至结束时间
+func (p *progress) snapshot(total int64) Progress {
+	return p.snapshotAt(total, p.end.Load())
+}
+
+// snapshotAt 计算截至 end (UnixNano，0 表示现在) 的进度
+func (p *progress) snapshotAt(total, end int64) Progress {
+	s := Progress{Bytes: p.n.Load(), Total: total, Done: p.done.Load()}
+	if start := p.start.Load(); start != 0 {
+		if end == 0 {
+			end = time.Now().UnixNano()
+		}
+		s.Elapsed = time.Duration(max(end-start, 0))
+	}
+	if s.Elapsed > 0 {
+		s.Rate = float64(s.Bytes) / s.Elapsed.Seconds()
+	}
+	if s.Total > s.Bytes && s.Rate > 0 {
+		s.ETA = time.Duration(float64(s.Total-s.Bytes) / s.Rate * float64(time.Second))
+	}
+	return s
+}
+
+// add 累加 n 字节，按 interval 与 step 节流调用 cb，结束时 (finished) 保证调用且只调用一次
+func (p *progress) add(n int, total int64, finished bool, err error, interval time.Duration, step float64, cb func(Progress)) {
+	p.n.Add(int64(n))
+	if finished && !p.finish() {
+		// 已经结束，只汇总多出的字节
+		if p.hook != nil && n > 0 {
+			p.hook(n, false, nil)
+		}
+		return
+	}
+	if p.hook != nil {
+		p.hook(n, finished, err)
+	}
+	if cb == nil {
+		return
+	}
+	if interval <= 0 && step <= 0 {
+		interval = DefaultProgressInterval
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.snapshot(total)
+	if !finished {
+		now := time.Now()
+		due := interval > 0 && now.Sub(p.lastTime) >= interval
+		if step > 0 && s.Percent()-p.lastPercent >= step {
+			due = true
+		}
+		if !due {
+			return
+		}
+		p.lastTime, p.lastPercent = now, s.Percent()
+	} else {
+		s.Done, s.Err = true, err
+	}
+	cb(s)
+}
+
+// ProgressReader 统计读取进度的 io.Reader，可以在其他协程中通过 Progress 查询进度。
+// OnProgress 按 Interval 或 Step 节流，两者都未设置时每 DefaultProgressInterval 最多调用一次，
+// 读取结束时总会以 Done 为 true 调用一次。回调串行执行，不应阻塞
 type ProgressReader struct {
 	io.Reader
-	Total      int64 // 总大小
-	ReadBytes  int64 // 已读取字节数
-	OnProgress func(readBytes int64, total int64)
+	Total int64 // 总大小，未知时为 0
+	// Context 结束后 Read 返回 ctx.Err()。已阻塞的 Read 不会被打断
+	Context context.Context
+	// Interval 两次回调之间的最短间隔
+	Interval time.Duration
+	// Step 完成百分比每增加 Step (0-100) 回调一次，需要 Total
+	Step       float64
+	OnProgress func(Progress)
+
+	p progress
 }
 
-func (pr *ProgressReader) Read(p []byte) (int, error) {
-	n, err := pr.Reader.Read(p)
-	pr.ReadBytes += int64(n)
-	if pr.OnProgress != nil {
-		pr.OnProgress(pr.ReadBytes, pr.Total)
+// NewProgressReader 创建读取 r 的 ProgressReader，ctx 结束后 Read 返回错误
+func NewProgressReader(ctx context.Context, r io.Reader, total int64, onProgress func(Progress)) *ProgressReader {
+	return &ProgressReader{Reader: r, Total: total, Context: ctx, OnProgress: onProgress}
+}
+
+func (pr *ProgressReader) Read(b []byte) (int, error) {
+	if pr.Context != nil {
+		if err := pr.Context.Err(); err != nil {
+			pr.p.add(0, pr.Total, true, err, pr.Interval, pr.Step, pr.OnProgress)
+			return 0, err
+		}
+	}
+	pr.p.begin()
+	n, err := pr.Reader.Read(b)
+	finished := err != nil || (pr.Total > 0 && pr.p.n.Load()+int64(n) >= pr.Total)
+	var cause error
+	if err != nil && err != io.EOF {
+		cause = err
 	}
+	pr.p.add(n, pr.Total, finished, cause, pr.Interval, pr.Step, pr.OnProgress)
 	return n, err
 }
+
+// BytesRead 返回已读取的字节数，可以并发调用
+func (pr *ProgressReader) BytesRead() int64 {
+	return pr.p.n.Load()
+}
+
+// Progress 返回当前进度，可以并发调用
+func (pr *ProgressReader) Progress() Progress {
+	return pr.p.snapshot(pr.Total)
+}
@@ -0,0 +1,134 @@
+package nats_client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// TestProgressReaderStep 测试按百分比步长节流，结束时回调一次且 Done 为 true
+func TestProgressReaderStep(t *testing.T) {
+	data := make([]byte, 1000)
+	var got []Progress
+	pr := &ProgressReader{
+		Reader:     iotest.OneByteReader(bytes.NewReader(data)),
+		Total:      int64(len(data)),
+		Step:       25,
+		OnProgress: func(p Progress) { got = append(got, p) },
+	}
+	if n, err := io.Copy(io.Discard, pr); err != nil || n != 1000 {
+		t.Fatalf("读取失败: %d %v", n, err)
+	}
+	// 25%、50%、75% 以及结束
+	if len(got) != 4 {
+		t.Fatalf("回调次数不匹配: %d", len(got))
+	}
+	for i, p := range got[:3] {
+		if p.Done || p.Percent() != float64(25*(i+1)) {
+			t.Errorf("第 %d 次回调不匹配: %+v", i, p)
+		}
+	}
+	last := got[3]
+	if !last.Done || last.Bytes != 1000 || last.Percent() != 100 || last.ETA != 0 || last.Err != nil {
+		t.Errorf("结束回调不匹配: %+v", last)
+	}
+	if p := pr.Progress(); !p.Done || p.Rate <= 0 || p.Elapsed <= 0 {
+		t.Errorf("结束后的进度不匹配: %+v", p)
+	}
+}
+
+// TestProgressReaderInterval 测试默认按时间间隔节流，速率与剩余时间
+func TestProgressReaderInterval(t *testing.T) {
+	var calls []Progress
+	pr := &ProgressReader{
+		Reader:     slowReader{bytes.NewReader(make([]byte, 100)), 10 * time.Millisecond},
+		Total:      200, // 实际只有 100 字节，ETA 不为 0
+		Interval:   30 * time.Millisecond,
+		OnProgress: func(p Progress) { calls = append(calls, p) },
+	}
+	buf := make([]byte, 10)
+	for i := 0; i < 10; i++ {
+		pr.Read(buf)
+	}
+	if n := len(calls); n < 2 || n > 5 {
+		t.Errorf("100ms 内按 30ms 间隔应回调 2 到 5 次: %d", n)
+	}
+	p := pr.Progress()
+	if p.Bytes != 100 || p.Done || p.Rate <= 0 || p.ETA <= 0 || p.Percent() != 50 {
+		t.Errorf("进度不匹配: %+v", p)
+	}
+}
+
+// TestProgressReaderCancel 测试 ctx 结束后 Read 返回错误并以错误结束进度
+func TestProgressReaderCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var last Progress
+	pr := NewProgressReader(ctx, bytes.NewReader(make([]byte, 100)), 100, func(p Progress) { last = p })
+	buf := make([]byte, 10)
+	if _, err := pr.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := pr.Read(buf); !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled: %v", err)
+	}
+	if !last.Done || !errors.Is(last.Err, context.Canceled) || last.Bytes != 10 {
+		t.Errorf("取消后的回调不匹配: %+v", last)
+	}
+}
+
+// TestProgressReaderFinished 测试结束后的进度截至结束时间，耗时与速率不再变化
+func TestProgressReaderFinished(t *testing.T) {
+	pr := NewProgressReader(context.Background(), bytes.NewReader(make([]byte, 100)), 100, nil)
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		t.Fatal(err)
+	}
+	before := pr.Progress()
+	time.Sleep(20 * time.Millisecond)
+	after := pr.Progress()
+	if !after.Done || after.Elapsed != before.Elapsed || after.Rate != before.Rate {
+		t.Errorf("结束后进度仍在变化: %+v -> %+v", before, after)
+	}
+}
+
+// TestProgressReaderConcurrent 测试读取的同时在其他协程查询进度 (配合 -race)
+func TestProgressReaderConcurrent(t *testing.T) {
+	pr := NewProgressReader(context.Background(), iotest.HalfReader(bytes.NewReader(make([]byte, 1<<16))), 1<<16, nil)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = pr.Progress()
+				_ = pr.BytesRead()
+			}
+		}
+	}()
+	io.Copy(io.Discard, pr)
+	close(stop)
+	wg.Wait()
+	if pr.BytesRead() != 1<<16 {
+		t.Errorf("读取字节数不匹配: %d", pr.BytesRead())
+	}
+}
+
+// slowReader 每次读取前等待 d
+type slowReader struct {
+	r io.Reader
+	d time.Duration
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.d)
+	return s.r.Read(p)
+}
@@ -0,0 +1,197 @@
+package nats_client
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// TransferState 单个传输的状态
+type TransferState string
+
+const (
+	TransferQueued  TransferState = "queued"  // 已登记，尚未开始读写
+	TransferRunning TransferState = "running" // 正在传输
+	TransferDone    TransferState = "done"    // 成功结束
+	TransferFailed  TransferState = "failed"  // 出错结束
+)
+
+// TransferStatus 单个传输的进度
+type TransferStatus struct {
+	Name  string
+	State TransferState
+	Progress
+}
+
+// TrackerProgress 全部传输的汇总进度，Progress 中的字节数与总大小为所有传输之和，
+// Done 表示所有已登记的传输都已结束
+type TrackerProgress struct {
+	Progress
+	Queued, Running, Succeeded, Failed int
+	Items                              []TransferStatus // 按登记顺序
+}
+
+// ProgressTracker 汇总多个并发传输的进度，例如同时上传几十个文件到对象存储。
+// 每个传输通过 Add 登记，用返回的 Transfer 包装读写，状态随读写自动变化。
+// OnProgress 在传输状态变化时立即调用，字节数变化按 Interval 节流，回调串行执行
+type ProgressTracker struct {
+	// Interval 两次字节进度回调的最短间隔，默认 DefaultProgressInterval
+	Interval   time.Duration
+	OnProgress func(TrackerProgress)
+
+	p     progress
+	mu    sync.Mutex
+	items []*Transfer
+}
+
+// NewProgressTracker 创建汇总进度跟踪器
+func NewProgressTracker(onProgress func(TrackerProgress)) *ProgressTracker {
+	return &ProgressTracker{OnProgress: onProgress}
+}
+
+// Transfer 跟踪器中的一个传输
+type Transfer struct {
+	t     *ProgressTracker
+	name  string
+	total int64
+
+	// 以下字段由 t.mu 保护
+	state    TransferState
+	err      error
+	p        *progress
+	attached bool // 已经有 Reader 或 Writer 计入该传输
+}
+
+// Add 登记一个传输，total 未知时为 0
+func (t *ProgressTracker) Add(name string, total int64) *Transfer {
+	tr := &Transfer{t: t, name: name, total: total, state: TransferQueued, p: &progress{}}
+	t.mu.Lock()
+	t.items = append(t.items, tr)
+	t.mu.Unlock()
+	t.notify(true)
+	return tr
+}
+
+// Reader 包装 r，读取进度计入该传输，读到 EOF 时成功结束，出错或 ctx 结束时失败。
+// 每个传输只计入第一次调用 Reader 或 Writer 返回的对象，之后返回的对象照常统计自身进度，
+// 但不计入该传输与汇总，避免同一份数据的读写两端重复计数
+func (tr *Transfer) Reader(ctx context.Context, r io.Reader) *ProgressReader {
+	pr := NewProgressReader(ctx, r, tr.total, nil)
+	tr.attach(&pr.p)
+	return pr
+}
+
+// Writer 包装 w，写入进度计入该传输，写满总大小时成功结束，
+// 总大小未知时需要调用 Done 结束。与 Reader 一样只有第一次返回的对象计入该传输
+func (tr *Transfer) Writer(ctx context.Context, w io.Writer) *ProgressWriter {
+	pw := NewProgressWriter(ctx, w, tr.total, nil)
+	tr.attach(&pw.p)
+	return pw
+}
+
+// attach 让 p 的读写计入该传输与汇总，已经有其他读写计入时忽略
+func (tr *Transfer) attach(p *progress) {
+	tr.t.mu.Lock()
+	if tr.attached {
+		tr.t.mu.Unlock()
+		return
+	}
+	tr.p, tr.attached = p, true
+	tr.t.mu.Unlock()
+	p.hook = func(n int, finished bool, err error) {
+		tr.t.p.begin()
+		tr.t.p.n.Add(int64(n))
+		changed := false
+		tr.t.mu.Lock()
+		if tr.state == TransferQueued {
+			tr.state, changed = TransferRunning, true
+		}
+		if finished && tr.state == TransferRunning {
+			tr.state, tr.err, changed = TransferDone, err, true
+			if err != nil {
+				tr.state = TransferFailed
+			}
+		}
+		tr.t.mu.Unlock()
+		tr.t.notify(changed)
+	}
+}
+
+// Done 以 err 结束传输，覆盖读写自动得出的结果。
+// 例如数据已全部读出但对象存储返回错误时，以该错误标记为失败
+func (tr *Transfer) Done(err error) {
+	tr.t.mu.Lock()
+	tr.p.finish()
+	tr.state, tr.err = TransferDone, err
+	if err != nil {
+		tr.state = TransferFailed
+	}
+	tr.t.mu.Unlock()
+	tr.t.notify(true)
+}
+
+// Status 返回该传输的当前进度
+func (tr *Transfer) Status() TransferStatus {
+	tr.t.mu.Lock()
+	defer tr.t.mu.Unlock()
+	return tr.status()
+}
+
+// status 调用方持有 t.mu
+func (tr *Transfer) status() TransferStatus {
+	s := TransferStatus{Name: tr.name, State: tr.state, Progress: tr.p.snapshot(tr.total)}
+	s.Done = tr.state == TransferDone || tr.state == TransferFailed
+	s.Err = tr.err
+	return s
+}
+
+// Progress 返回汇总进度，可以并发调用
+func (t *ProgressTracker) Progress() TrackerProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var r TrackerProgress
+	var end int64 // 最后一项结束的时间，全部结束后汇总进度截至此时
+	for _, tr := range t.items {
+		end = max(end, tr.p.end.Load())
+		s := tr.status()
+		r.Items = append(r.Items, s)
+		r.Total += s.Total
+		switch s.State {
+		case TransferQueued:
+			r.Queued++
+		case TransferRunning:
+			r.Running++
+		case TransferDone:
+			r.Succeeded++
+		case TransferFailed:
+			r.Failed++
+		}
+	}
+	r.Done = len(t.items) > 0 && r.Queued == 0 && r.Running == 0
+	if !r.Done {
+		end = 0
+	}
+	agg := t.p.snapshotAt(r.Total, end)
+	r.Bytes, r.Elapsed, r.Rate, r.ETA = agg.Bytes, agg.Elapsed, agg.Rate, agg.ETA
+	return r
+}
+
+// notify 按节流调用 OnProgress，force 为 true 时 (状态变化) 立即调用
+func (t *ProgressTracker) notify(force bool) {
+	if t.OnProgress == nil {
+		return
+	}
+	interval := t.Interval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+	now := time.Now()
+	if !force && now.Sub(t.p.lastTime) < interval {
+		return
+	}
+	t.p.lastTime = now
+	t.OnProgress(t.Progress())
+}
@@ -0,0 +1,154 @@
+package nats_client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// TestProgressWriter 测试写满总大小时结束，写入出错时以错误结束
+func TestProgressWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var last Progress
+	calls := 0
+	pw := NewProgressWriter(context.Background(), &buf, 1000, func(p Progress) { calls++; last = p })
+	pw.Step = 50
+	if n, err := io.Copy(pw, iotest.OneByteReader(bytes.NewReader(make([]byte, 1000)))); err != nil || n != 1000 {
+		t.Fatalf("写入失败: %d %v", n, err)
+	}
+	if calls != 2 || !last.Done || last.Bytes != 1000 || last.Err != nil || buf.Len() != 1000 {
+		t.Errorf("回调不匹配: %d %+v", calls, last)
+	}
+	pw.Finish(errors.New("late"))
+	if calls != 2 {
+		t.Error("结束后不应再回调")
+	}
+
+	// 总大小未知时由 Finish 结束
+	errFull := errors.New("disk full")
+	pw = NewProgressWriter(context.Background(), failWriter{10, errFull}, 0, func(p Progress) { last = p })
+	if _, err := io.Copy(pw, bytes.NewReader(make([]byte, 100))); !errors.Is(err, errFull) {
+		t.Fatalf("期望写入错误: %v", err)
+	}
+	if !last.Done || !errors.Is(last.Err, errFull) || pw.BytesWritten() != 10 {
+		t.Errorf("出错后的回调不匹配: %+v", last)
+	}
+}
+
+// TestProgressTracker 测试并发传输的汇总字节数与每项状态 (配合 -race)
+func TestProgressTracker(t *testing.T) {
+	var mu sync.Mutex
+	var calls []TrackerProgress
+	tk := NewProgressTracker(func(p TrackerProgress) {
+		mu.Lock()
+		calls = append(calls, p)
+		mu.Unlock()
+	})
+	const n, size = 8, 1 << 14
+	ctx := context.Background()
+	var transfers []*Transfer
+	for i := 0; i < n; i++ {
+		transfers = append(transfers, tk.Add(fmt.Sprintf("artifact-%d", i), size))
+	}
+	idle := tk.Add("idle", 100)
+	if p := tk.Progress(); p.Queued != n+1 || p.Total != n*size+100 || p.Done {
+		t.Fatalf("登记后的进度不匹配: %+v", p)
+	}
+
+	errBroken := errors.New("broken")
+	var wg sync.WaitGroup
+	for i, tr := range transfers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src := iotest.HalfReader(bytes.NewReader(make([]byte, size)))
+			switch i {
+			case 0: // 下载方向
+				io.Copy(tr.Writer(ctx, io.Discard), src)
+			case 1: // 读取中途出错
+				io.Copy(io.Discard, tr.Reader(ctx, io.MultiReader(io.LimitReader(src, size/2), iotest.ErrReader(errBroken))))
+			default:
+				io.Copy(io.Discard, tr.Reader(ctx, src))
+			}
+		}()
+	}
+	wg.Wait()
+
+	p := tk.Progress()
+	if p.Succeeded != n-1 || p.Failed != 1 || p.Queued != 1 || p.Done {
+		t.Fatalf("传输结束后的状态不匹配: %+v", p)
+	}
+	if want := int64((n-1)*size + size/2); p.Bytes != want {
+		t.Errorf("汇总字节数不匹配: %d != %d", p.Bytes, want)
+	}
+	if s := p.Items[1]; s.State != TransferFailed || !errors.Is(s.Err, errBroken) || !s.Done {
+		t.Errorf("失败项不匹配: %+v", s)
+	}
+	if s := p.Items[n]; s.Name != "idle" || s.State != TransferQueued {
+		t.Errorf("未开始的项不匹配: %+v", s)
+	}
+
+	// Done 覆盖自动得出的结果
+	errStore := errors.New("store unavailable")
+	transfers[2].Done(errStore)
+	idle.Done(nil)
+	p = tk.Progress()
+	if !p.Done || p.Failed != 2 || p.Succeeded != n-1 || transfers[2].Status().Err != errStore {
+		t.Errorf("全部结束后的进度不匹配: %+v", p)
+	}
+	// 全部结束后汇总耗时与速率截至最后一项结束
+	time.Sleep(20 * time.Millisecond)
+	if again := tk.Progress(); again.Elapsed != p.Elapsed || again.Rate != p.Rate {
+		t.Errorf("结束后汇总进度仍在变化: %v -> %v", p.Elapsed, again.Elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 每项登记与状态变化至少各回调一次 (一次读取就完成时开始与结束合并)，外加两次 Done
+	if len(calls) < 2*n+1+2 {
+		t.Errorf("状态变化应立即回调: %d", len(calls))
+	}
+	if last := calls[len(calls)-1]; !last.Done {
+		t.Errorf("最后一次回调应为全部结束: %+v", last)
+	}
+}
+
+// TestTransferAttachOnce 测试同一传输的读写两端只计入一次
+func TestTransferAttachOnce(t *testing.T) {
+	tk := NewProgressTracker(nil)
+	tr := tk.Add("copy", 100)
+	ctx := context.Background()
+	pw := tr.Writer(ctx, io.Discard)
+	pr := tr.Reader(ctx, bytes.NewReader(make([]byte, 100)))
+	if _, err := io.Copy(pw, pr); err != nil {
+		t.Fatal(err)
+	}
+	if p := tk.Progress(); p.Bytes != 100 || p.Succeeded != 1 {
+		t.Errorf("汇总进度不匹配: %+v", p)
+	}
+	if s := tr.Status(); s.Bytes != 100 {
+		t.Errorf("传输进度不匹配: %+v", s)
+	}
+	if pr.BytesRead() != 100 {
+		t.Errorf("未计入的 Reader 仍应统计自身进度: %d", pr.BytesRead())
+	}
+}
+
+// failWriter 写入 n 字节后返回 err
+type failWriter struct {
+	n   int
+	err error
+}
+
+func (w failWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, w.err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,57 @@
+package nats_client
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ProgressWriter 统计写入进度的 io.Writer，用于下载方向，例如 io.Copy(file, pw)。
+// 节流与回调规则与 ProgressReader 相同；写满 Total、写入出错或调用 Finish 时结束
+type ProgressWriter struct {
+	io.Writer
+	Total int64 // 总大小，未知时为 0，需要调用 Finish 结束
+	// Context 结束后 Write 返回 ctx.Err()
+	Context context.Context
+	// Interval 两次回调之间的最短间隔
+	Interval time.Duration
+	// Step 完成百分比每增加 Step (0-100) 回调一次，需要 Total
+	Step       float64
+	OnProgress func(Progress)
+
+	p progress
+}
+
+// NewProgressWriter 创建写入 w 的 ProgressWriter，ctx 结束后 Write 返回错误
+func NewProgressWriter(ctx context.Context, w io.Writer, total int64, onProgress func(Progress)) *ProgressWriter {
+	return &ProgressWriter{Writer: w, Total: total, Context: ctx, OnProgress: onProgress}
+}
+
+func (pw *ProgressWriter) Write(b []byte) (int, error) {
+	if pw.Context != nil {
+		if err := pw.Context.Err(); err != nil {
+			pw.Finish(err)
+			return 0, err
+		}
+	}
+	pw.p.begin()
+	n, err := pw.Writer.Write(b)
+	finished := err != nil || (pw.Total > 0 && pw.p.n.Load()+int64(n) >= pw.Total)
+	pw.p.add(n, pw.Total, finished, err, pw.Interval, pw.Step, pw.OnProgress)
+	return n, err
+}
+
+// Finish 结束传输，err 为 nil 表示成功。已经结束时不再回调
+func (pw *ProgressWriter) Finish(err error) {
+	pw.p.add(0, pw.Total, true, err, pw.Interval, pw.Step, pw.OnProgress)
+}
+
+// BytesWritten 返回已写入的字节数，可以并发调用
+func (pw *ProgressWriter) BytesWritten() int64 {
+	return pw.p.n.Load()
+}
+
+// Progress 返回当前进度，可以并发调用
+func (pw *ProgressWriter) Progress() Progress {
+	return pw.p.snapshot(pw.Total)
+}
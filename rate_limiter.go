@@ -0,0 +1,172 @@
+package nats_client
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter 按字节计数的令牌桶限速器，可以在运行时调整速率。
+// 同一个 RateLimiter 可以被多个 RateLimitedReader / RateLimitedWriter 共享，实现全局限速；
+// 每个传输使用各自的 RateLimiter 则为单独限速。nil 表示不限速，所有方法都可以在 nil 上调用
+type RateLimiter struct {
+	mu sync.RWMutex // 写锁保护速率与突发量的修改，保证预留的字节数不超过突发量
+	l  *rate.Limiter
+}
+
+// NewRateLimiter 创建限速器，bytesPerSec 为每秒字节数，不大于 0 时不限速。
+// burst 为令牌桶容量，也是单次读写的最大字节数，不大于 0 时为一秒的字节数
+func NewRateLimiter(bytesPerSec float64, burst int) *RateLimiter {
+	limit, burst := rateLimit(bytesPerSec, burst)
+	return &RateLimiter{l: rate.NewLimiter(limit, burst)}
+}
+
+// SetLimit 调整速率与突发量，参数含义与 NewRateLimiter 相同。
+// 正在等待的读写按原速率完成当前这一块，之后的读写使用新的速率。l 为 nil 时无效
+func (l *RateLimiter) SetLimit(bytesPerSec float64, burst int) {
+	if l == nil {
+		return
+	}
+	limit, burst := rateLimit(bytesPerSec, burst)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.l.SetLimit(limit)
+	l.l.SetBurst(burst)
+}
+
+// rateLimit 把每秒字节数与突发量转换为令牌桶参数
+func rateLimit(bytesPerSec float64, burst int) (rate.Limit, int) {
+	if bytesPerSec <= 0 {
+		return rate.Inf, max(burst, 0)
+	}
+	if burst <= 0 {
+		burst = max(int(bytesPerSec), 1)
+	}
+	return rate.Limit(bytesPerSec), burst
+}
+
+// Limit 返回当前的每秒字节数与突发量，不限速时字节数为 0
+func (l *RateLimiter) Limit() (bytesPerSec float64, burst int) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.l.Limit() == rate.Inf {
+		return 0, l.l.Burst()
+	}
+	return float64(l.l.Limit()), l.l.Burst()
+}
+
+// chunk 返回单次读写的最大字节数，不限速时返回 n
+func (l *RateLimiter) chunk(n int) int {
+	if l == nil {
+		return n
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.l.Limit() == rate.Inf {
+		return n
+	}
+	return min(n, l.l.Burst())
+}
+
+// wait 等待 n 字节的令牌，ctx 结束时返回 ctx.Err() 并归还尚未使用的令牌
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	for n > 0 {
+		l.mu.RLock()
+		c := n
+		if l.l.Limit() != rate.Inf {
+			c = min(n, l.l.Burst())
+		}
+		r := l.l.ReserveN(time.Now(), c)
+		l.mu.RUnlock()
+		n -= c
+		if d := r.Delay(); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				r.Cancel()
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// RateLimitedReader 按 Limiter 限速的 io.Reader，每次读取不超过突发量，读取后等待令牌。
+// 与 ProgressReader 组合即可在限速的同时报告进度
+type RateLimitedReader struct {
+	io.Reader
+	Limiter *RateLimiter
+	// Context 结束后 Read 返回 ctx.Err()，也会打断令牌等待
+	Context context.Context
+}
+
+// NewRateLimitedReader 创建读取 r 的限速 Reader
+func NewRateLimitedReader(ctx context.Context, r io.Reader, l *RateLimiter) *RateLimitedReader {
+	return &RateLimitedReader{Reader: r, Limiter: l, Context: ctx}
+}
+
+func (lr *RateLimitedReader) Read(b []byte) (int, error) {
+	ctx := lr.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return lr.Reader.Read(b)
+	}
+	n, err := lr.Reader.Read(b[:max(lr.Limiter.chunk(len(b)), 1)])
+	if werr := lr.Limiter.wait(ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// RateLimitedWriter 按 Limiter 限速的 io.Writer，按突发量分块写入，每块写入前等待令牌。
+// 与 ProgressWriter 组合即可在限速的同时报告进度
+type RateLimitedWriter struct {
+	io.Writer
+	Limiter *RateLimiter
+	// Context 结束后 Write 返回 ctx.Err()，也会打断令牌等待
+	Context context.Context
+}
+
+// NewRateLimitedWriter 创建写入 w 的限速 Writer
+func NewRateLimitedWriter(ctx context.Context, w io.Writer, l *RateLimiter) *RateLimitedWriter {
+	return &RateLimitedWriter{Writer: w, Limiter: l, Context: ctx}
+}
+
+func (lw *RateLimitedWriter) Write(b []byte) (int, error) {
+	ctx := lw.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	written := 0
+	for written < len(b) {
+		c := max(lw.Limiter.chunk(len(b)-written), 1)
+		if err := lw.Limiter.wait(ctx, c); err != nil {
+			return written, err
+		}
+		n, err := lw.Writer.Write(b[written : written+c])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,120 @@
+package nats_client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRateLimitedReader 测试读取按速率限速，并与 ProgressReader 组合报告进度
+func TestRateLimitedReader(t *testing.T) {
+	lim := NewRateLimiter(100<<10, 10<<10) // 100KiB/s，突发 10KiB
+	ctx := context.Background()
+	var last Progress
+	pr := NewProgressReader(ctx, NewRateLimitedReader(ctx, bytes.NewReader(make([]byte, 50<<10)), lim), 50<<10, func(p Progress) { last = p })
+	start := time.Now()
+	if n, err := io.Copy(io.Discard, pr); err != nil || n != 50<<10 {
+		t.Fatalf("读取失败: %d %v", n, err)
+	}
+	// 首个突发不等待，其余 40KiB 约需 400ms
+	if d := time.Since(start); d < 300*time.Millisecond || d > 2*time.Second {
+		t.Errorf("限速后的耗时不匹配: %v", d)
+	}
+	if !last.Done || last.Rate > 150<<10 {
+		t.Errorf("进度不匹配: %+v", last)
+	}
+}
+
+// TestRateLimitedWriter 测试运行时调整速率，以及多个传输共享一个限速器
+func TestRateLimitedWriter(t *testing.T) {
+	lim := NewRateLimiter(1<<10, 1<<10) // 1KiB/s，按此速率写完需要几十秒
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var bufs [2]bytes.Buffer
+	start := time.Now()
+	for i := range bufs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := NewRateLimitedWriter(ctx, &bufs[i], lim)
+			if _, err := w.Write(make([]byte, 20<<10)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	if bps, burst := lim.Limit(); bps != 1<<10 || burst != 1<<10 {
+		t.Errorf("速率不匹配: %v %d", bps, burst)
+	}
+	// 提高到 200KiB/s，两个传输共享，剩余约 38KiB 约需 200ms
+	lim.SetLimit(200<<10, 8<<10)
+	wg.Wait()
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("调整速率后未加速: %v", d)
+	}
+	if bufs[0].Len() != 20<<10 || bufs[1].Len() != 20<<10 {
+		t.Errorf("写入字节数不匹配: %d %d", bufs[0].Len(), bufs[1].Len())
+	}
+
+	lim.SetLimit(0, 0)
+	if bps, _ := lim.Limit(); bps != 0 {
+		t.Errorf("应不限速: %v", bps)
+	}
+	var buf bytes.Buffer
+	if n, err := NewRateLimitedWriter(ctx, &buf, lim).Write(make([]byte, 1<<20)); err != nil || n != 1<<20 {
+		t.Errorf("不限速写入失败: %d %v", n, err)
+	}
+}
+
+// TestRateLimitedCancel 测试 ctx 结束时打断令牌等待
+func TestRateLimitedCancel(t *testing.T) {
+	lim := NewRateLimiter(1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w := NewRateLimitedWriter(ctx, io.Discard, lim)
+	start := time.Now()
+	n, err := w.Write(make([]byte, 10))
+	if !errors.Is(err, context.DeadlineExceeded) || n != 1 {
+		t.Errorf("期望超时: %d %v", n, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("等待未被打断: %v", d)
+	}
+}
+
+// TestRateLimiterNil 测试 nil 限速器不限速，读写直接透传
+func TestRateLimiterNil(t *testing.T) {
+	var lim *RateLimiter
+	lim.SetLimit(1, 1)
+	if bps, burst := lim.Limit(); bps != 0 || burst != 0 {
+		t.Errorf("nil 限速器的速率应为 0: %v %v", bps, burst)
+	}
+	data := make([]byte, 1<<20)
+	var buf bytes.Buffer
+	start := time.Now()
+	if _, err := io.Copy(NewRateLimitedWriter(context.Background(), &buf, lim), NewRateLimitedReader(context.Background(), bytes.NewReader(data), lim)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != len(data) || time.Since(start) > time.Second {
+		t.Errorf("nil 限速器不应限速: %d 字节，耗时 %v", buf.Len(), time.Since(start))
+	}
+}
+
+// TestRateLimiterWaitGranted 测试令牌已经发放时 wait 返回 nil，即使 ctx 随后结束
+func TestRateLimiterWaitGranted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewRateLimiter(0, 0).wait(ctx, 1<<20); err != nil {
+		t.Errorf("不限速时令牌立即发放，期望 nil: %v", err)
+	}
+	if err := NewRateLimiter(1<<20, 0).wait(ctx, 10); err != nil {
+		t.Errorf("令牌充足时期望 nil: %v", err)
+	}
+	if _, err := NewRateLimitedWriter(ctx, io.Discard, nil).Write([]byte("x")); !errors.Is(err, context.Canceled) {
+		t.Errorf("ctx 结束后 Write 应返回 context.Canceled: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package nats_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// setupProxyConnection 通过故障注入代理连接内嵌服务器
+func setupProxyConnection(t *testing.T, events chan Event) (*nats.Conn, *natstest.Proxy) {
+	t.Helper()
+	s := natstest.RunServer(t)
+	p := natstest.RunProxy(t, s.Addr().String())
+	nc, err := Connect(
+		WithURL(p.URL()),
+		WithName("nats-client-resilience"),
+		WithDialer(nil),
+		WithReconnect(-1, 20*time.Millisecond),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("通过代理连接失败: %v", err)
+	}
+	t.Cleanup(nc.Close)
+	return nc, p
+}
+
+// TestQueueSubscribeSurvivesReset 测试连接被重置后队列订阅继续接收消息
+func TestQueueSubscribeSurvivesReset(t *testing.T) {
+	events := make(chan Event, 64)
+	nc, p := setupProxyConnection(t, events)
+
+	jsc, err := testJetStream(nc)
+	if err != nil {
+		t.Fatalf("获取JetStream失败: %v", err)
+	}
+	js := jsc.Legacy
+	setupEventsStream(t, js)
+
+	received := make(chan string, 16)
+	sub, err := js.QueueSubscribe("events.resilience", "workers", func(msg *nats.Msg) {
+		msg.Ack()
+		received <- string(msg.Data)
+	}, nats.Durable("resilience-workers"))
+	if err != nil {
+		t.Fatalf("队列订阅失败: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	publish := func(data string) {
+		t.Helper()
+		if _, err := js.Publish("events.resilience", []byte(data), nats.RetryAttempts(20), nats.RetryWait(50*time.Millisecond)); err != nil {
+			t.Fatalf("发布消息失败: %v", err)
+		}
+	}
+	expect := func(data string) {
+		t.Helper()
+		select {
+		case got := <-received:
+			if got != data {
+				t.Errorf("消息不匹配: got %q, want %q", got, data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待消息 %q 超时", data)
+		}
+	}
+
+	publish("before")
+	expect("before")
+
+	p.ResetConnections()
+	waitEvent(t, events, EventDisconnected)
+	waitEvent(t, events, EventReconnected)
+
+	publish("after")
+	expect("after")
+}
+
+// TestPullConsumerRedeliveryAfterLostAck 测试停顿时确认超时，
+// 确认随连接重置丢失后消息在 AckWait 到期后被重新投递
+func TestPullConsumerRedeliveryAfterLostAck(t *testing.T) {
+	events := make(chan Event, 64)
+	nc, p := setupProxyConnection(t, events)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	js, err := testJetStream(nc)
+	if err != nil {
+		t.Fatalf("获取JetStream失败: %v", err)
+	}
+	setupEventsStream(t, js.Legacy)
+	cons, err := js.CreateOrUpdateConsumer(ctx, "EVENTS", jetstream.ConsumerConfig{
+		Durable:       "redelivery",
+		FilterSubject: "events.redelivery",
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("创建消费者失败: %v", err)
+	}
+	if _, err := js.Publish(ctx, "events.redelivery", []byte("job-1")); err != nil {
+		t.Fatalf("发布消息失败: %v", err)
+	}
+
+	fetchOne := func() jetstream.Msg {
+		t.Helper()
+		batch, err := cons.Fetch(1, jetstream.FetchMaxWait(3*time.Second))
+		if err != nil {
+			t.Fatalf("拉取消息失败: %v", err)
+		}
+		for msg := range batch.Messages() {
+			return msg
+		}
+		t.Fatalf("未拉取到消息: %v", batch.Error())
+		return nil
+	}
+
+	msg := fetchOne()
+	p.Stall()
+	ackCtx, ackCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	err = msg.DoubleAck(ackCtx)
+	ackCancel()
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
+		t.Fatalf("停顿时确认应超时，实际: %v", err)
+	}
+
+	// 重置连接，代理中缓存的确认被丢弃
+	p.ResetConnections()
+	p.Resume()
+	waitEvent(t, events, EventReconnected)
+
+	redelivered := fetchOne()
+	meta, err := redelivered.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(redelivered.Data()) != "job-1" || meta.NumDelivered != 2 {
+		t.Errorf("重新投递不匹配: data=%q delivered=%d", redelivered.Data(), meta.NumDelivered)
+	}
+	if err := redelivered.DoubleAck(ctx); err != nil {
+		t.Errorf("确认重新投递的消息失败: %v", err)
+	}
+}
+
+// TestPullFetchUnderLatency 测试在高延迟与带宽受限的链路上拉取消息
+func TestPullFetchUnderLatency(t *testing.T) {
+	events := make(chan Event, 64)
+	nc, p := setupProxyConnection(t, events)
+
+	jsc, err := testJetStream(nc)
+	if err != nil {
+		t.Fatalf("获取JetStream失败: %v", err)
+	}
+	js := jsc.Legacy
+	setupEventsStream(t, js)
+	sub, err := js.PullSubscribe("events.slowlink", "slowlink")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		if _, err := js.Publish("events.slowlink", []byte(fmt.Sprintf("msg-%d", i))); err != nil {
+			t.Fatalf("发布消息失败: %v", err)
+		}
+	}
+
+	p.SetLatency(100 * time.Millisecond)
+	p.SetBandwidth(32 * 1024)
+	start := time.Now()
+	msgs, err := sub.Fetch(count, nats.MaxWait(5*time.Second))
+	if err != nil {
+		t.Fatalf("拉取消息失败: %v", err)
+	}
+	if len(msgs) != count {
+		t.Errorf("拉取的消息数量不匹配: %d", len(msgs))
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("拉取应至少经历一次往返延迟: %v", d)
+	}
+	for _, m := range msgs {
+		m.Ack()
+	}
+}
@@ -0,0 +1,151 @@
+package nats_client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Backoff 首次连接失败后的重试间隔，按 Multiplier 指数增长到 Max，
+// 每次在 ±Jitter 比例内随机抖动，避免大量实例同时重试
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64 // 0 到 1 之间
+}
+
+// DefaultBackoff ConnectContext 默认的重试间隔
+var DefaultBackoff = Backoff{
+	Initial:    100 * time.Millisecond,
+	Max:        5 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Delay 返回第 attempt 次 (从 1 开始) 失败后的等待时间
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Initial <= 0 {
+		b.Initial = DefaultBackoff.Initial
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = DefaultBackoff.Multiplier
+	}
+	d := float64(b.Initial)
+	for i := 1; i < attempt && (b.Max <= 0 || d < float64(b.Max)); i++ {
+		d *= b.Multiplier
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// IsAuthError 判断是否为认证或授权失败，这类错误重试也不会成功
+func IsAuthError(err error) bool {
+	var pe *ProxyError
+	if errors.As(err, &pe) && pe.StatusCode == 407 {
+		return true
+	}
+	return errors.Is(err, nats.ErrAuthorization) ||
+		errors.Is(err, nats.ErrAuthExpired) ||
+		errors.Is(err, nats.ErrAuthRevoked) ||
+		errors.Is(err, nats.ErrAccountAuthExpired)
+}
+
+// ConnectContext 使用选项构建配置并建立连接，服务器尚未就绪时按退避间隔重试，
+// 直到连接成功、遇到认证错误或 ctx 结束
+func ConnectContext(ctx context.Context, opts ...Option) (*nats.Conn, error) {
+	cfg, err := NewConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.ConnectContext(ctx)
+}
+
+// ConnectContext 建立连接，服务器不可达等临时错误时重试 (见 IsTransientError)，每次失败产生 EventConnectRetry 事件。
+// 其他错误立即返回；每次尝试的连接超时不超过 ctx 的剩余时间，ctx 结束时返回 ctx 的错误与最后一次连接错误
+func (c *Config) ConnectContext(ctx context.Context) (*nats.Conn, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cfg := c
+		if deadline, ok := ctx.Deadline(); ok {
+			if d := time.Until(deadline); c.Timeout <= 0 || d < c.Timeout {
+				bounded := *c
+				bounded.Timeout = max(d, time.Millisecond)
+				cfg = &bounded
+			}
+		}
+		nc, err := cfg.Connect()
+		if err == nil {
+			return nc, nil
+		}
+		if !IsTransientError(err) {
+			return nil, err
+		}
+		delay := c.ConnectBackoff.Delay(attempt)
+		c.emit(nil, Event{Type: EventConnectRetry, Server: redactURLs(c.URL), Attempt: attempt, Delay: delay, Err: err})
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, errors.Join(ctx.Err(), err)
+		case <-t.C:
+		}
+	}
+}
+
+// IsTransientError 判断连接错误是否为临时错误，重试可能成功：
+// 没有可用的服务器、网络错误、连接被关闭，以及 407 以外的代理错误
+func IsTransientError(err error) bool {
+	// syscall.Errno 也实现了 net.Error，读取凭证等本地文件的错误不能当作网络错误
+	var fe *fs.PathError
+	if IsAuthError(err) || errors.As(err, &fe) {
+		return false
+	}
+	var pe *ProxyError
+	if errors.As(err, &pe) {
+		return true
+	}
+	var ne net.Error
+	return errors.Is(err, nats.ErrNoServers) ||
+		errors.As(err, &ne) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// redactURLs 隐藏地址列表中的密码
+func redactURLs(urls string) string {
+	parts := strings.Split(urls, ",")
+	for i, s := range parts {
+		if u, err := url.Parse(strings.TrimSpace(s)); err == nil && u.User != nil {
+			parts[i] = u.Redacted()
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// WithConnectBackoff 设置 ConnectContext 的重试间隔
+func WithConnectBackoff(b Backoff) Option {
+	return func(c *Config) error {
+		c.ConnectBackoff = b
+		return nil
+	}
+}
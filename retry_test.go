@@ -0,0 +1,158 @@
+package nats_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// TestBackoffDelay 测试指数增长、上限与抖动范围
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := b.Delay(i + 1); got != w*time.Millisecond {
+			t.Errorf("Delay(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := b.Delay(3); d < 200*time.Millisecond || d > 600*time.Millisecond {
+			t.Fatalf("抖动超出范围: %v", d)
+		}
+	}
+}
+
+// TestConnectContextRetry 测试服务器稍后启动时重试直到连接成功
+func TestConnectContextRetry(t *testing.T) {
+	port := natstest.FreePort(t)
+	started := make(chan *server.Server, 1)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		s, err := natstest.StartServer(natstest.WithPort(port))
+		if err != nil {
+			t.Errorf("启动服务器失败: %v", err)
+		}
+		started <- s
+	}()
+	t.Cleanup(func() {
+		if s := <-started; s != nil {
+			s.Shutdown()
+		}
+	})
+
+	events := make(chan Event, 64)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	nc, err := ConnectContext(ctx,
+		WithURL(fmt.Sprintf("nats://127.0.0.1:%d", port)),
+		WithDialer(nil),
+		WithConnectBackoff(Backoff{Initial: 20 * time.Millisecond, Max: 100 * time.Millisecond, Multiplier: 2}),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("重试连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	e := waitEvent(t, events, EventConnectRetry)
+	if e.Attempt != 1 || e.Delay != 20*time.Millisecond || e.Err == nil {
+		t.Errorf("重试事件不匹配: %v", e)
+	}
+	waitEvent(t, events, EventConnected)
+}
+
+// TestConnectContextAuthError 测试认证失败时不重试
+func TestConnectContextAuthError(t *testing.T) {
+	s := natstest.RunServer(t, func(o *server.Options) { o.Authorization = "s3cret" })
+	events := make(chan Event, 64)
+	start := time.Now()
+	_, err := ConnectContext(context.Background(),
+		WithURL(s.ClientURL()),
+		WithToken("wrong"),
+		WithDialer(nil),
+		WithEventChannel(events),
+	)
+	if !errors.Is(err, nats.ErrAuthorization) || !IsAuthError(err) {
+		t.Fatalf("期望认证错误，实际: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("认证错误不应重试")
+	}
+	for len(events) > 0 {
+		if e := <-events; e.Type == EventConnectRetry {
+			t.Errorf("认证错误不应产生重试事件: %v", e)
+		}
+	}
+}
+
+// TestConnectContextCancel 测试 ctx 结束时停止重试
+func TestConnectContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := ConnectContext(ctx,
+		WithURL(fmt.Sprintf("nats://127.0.0.1:%d", natstest.FreePort(t))),
+		WithDialer(nil),
+		WithConnectBackoff(Backoff{Initial: 50 * time.Millisecond}),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望 context.DeadlineExceeded，实际: %v", err)
+	}
+}
+
+// TestConnectContextPermanentError 测试凭证文件缺失等非临时错误立即返回，不重试
+func TestConnectContextPermanentError(t *testing.T) {
+	s := natstest.RunServer(t)
+	events := make(chan Event, 64)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := ConnectContext(ctx,
+		WithURL(s.ClientURL()),
+		WithCredsFile(filepath.Join(t.TempDir(), "missing.creds")),
+		WithDialer(nil),
+		WithEventChannel(events),
+	)
+	if err == nil || IsTransientError(err) {
+		t.Fatalf("期望非临时错误，实际: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("非临时错误不应重试")
+	}
+	for len(events) > 0 {
+		if e := <-events; e.Type == EventConnectRetry {
+			t.Errorf("非临时错误不应产生重试事件: %v", e)
+		}
+	}
+}
+
+// TestIsTransientError 测试临时错误的判断
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nats.ErrNoServers, true},
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{fmt.Errorf("read: %w", io.EOF), true},
+		{&ProxyError{StatusCode: 502, Err: errors.New("bad gateway")}, true},
+		{&ProxyError{StatusCode: 407, Err: errors.New("auth required")}, false},
+		{nats.ErrAuthorization, false},
+		{&os.PathError{Op: "open", Path: "x.creds", Err: os.ErrNotExist}, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %v, 期望 %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -2,24 +2,22 @@ package nats_client
 
 import (
 	"fmt"
-	"log"
 	"testing"
 
 	"github.com/nats-io/nats.go"
 )
 
 func TestStreamPub(t *testing.T) {
-	nc, err := NewNATSConnect()
-	if err != nil {
-		log.Fatalf("连接失败: %v", err)
-	}
+	nc := setupTestConnection(t)
 	defer nc.Close()
+	log := testLogger(t)
 
 	// 2. 获取 JetStream 上下文
-	js, err := nc.JetStream(nats.Domain("hub"))
+	jsc, err := testJetStream(nc)
 	if err != nil {
-		log.Fatalf("获取JetStream失败: %v", err)
+		t.Fatalf("获取JetStream失败: %v", err)
 	}
+	js := jsc.Legacy
 
 	// 3. 创建/更新一个持久化流
 	streamName := "EVENTS"
@@ -30,29 +28,29 @@ func TestStreamPub(t *testing.T) {
 		Storage:  nats.FileStorage, // 持久化到磁盘
 	})
 	if err != nil && err != nats.ErrStreamNameAlreadyInUse {
-		log.Fatalf("添加流失败: %v", err)
+		t.Fatalf("添加流失败: %v", err)
 	}
 
 	// 4. 发布持久化消息
 	for i := 1; i <= 3; i++ {
 		ack, err := js.Publish("events.user.1", []byte(fmt.Sprintf(`{"msg":"消息 #%d"}`, i)))
 		if err != nil {
-			log.Fatalf("发布消息失败: %v", err)
+			t.Fatalf("发布消息失败: %v", err)
 		}
-		fmt.Printf("已发布消息: %s, 序号: %d\n", ack.Stream, ack.Sequence)
+		log.Info("message published", LogKeySubject, subject, LogKeyStream, ack.Stream, LogKeySeq, ack.Sequence)
 	}
 	for i := 1; i <= 3; i++ {
 		ack, err := js.Publish("events.user.2", []byte(fmt.Sprintf(`{"msg":"消息 #%d"}`, i)))
 		if err != nil {
-			log.Fatalf("发布消息失败: %v", err)
+			t.Fatalf("发布消息失败: %v", err)
 		}
-		fmt.Printf("已发布消息: %s, 序号: %d\n", ack.Stream, ack.Sequence)
+		log.Info("message published", LogKeySubject, subject, LogKeyStream, ack.Stream, LogKeySeq, ack.Sequence)
 	}
 	for i := 1; i <= 3; i++ {
 		ack, err := js.Publish("events.admin.2", []byte(fmt.Sprintf(`{"msg":"消息 #%d"}`, i)))
 		if err != nil {
-			log.Fatalf("发布消息失败: %v", err)
+			t.Fatalf("发布消息失败: %v", err)
 		}
-		fmt.Printf("已发布消息: %s, 序号: %d\n", ack.Stream, ack.Sequence)
+		log.Info("message published", LogKeySubject, subject, LogKeyStream, ack.Stream, LogKeySeq, ack.Sequence)
 	}
 }
@@ -2,7 +2,6 @@ package nats_client
 
 import (
 	"fmt"
-	"log"
 	"testing"
 	"time"
 
@@ -11,38 +10,53 @@ import (
 
 func TestStreamPullSub(t *testing.T) {
 	// 1. 连接到 NATS 服务器
-	nc, err := NewNATSConnect()
-	if err != nil {
-		log.Fatalf("连接失败: %v", err)
-	}
+	nc := setupTestConnection(t)
 	defer nc.Close()
+	log := testLogger(t)
 
 	// 2. 获取 JetStream 上下文
-	js, err := nc.JetStream(nats.Domain("hub"))
+	jsc, err := testJetStream(nc)
 	if err != nil {
-		log.Fatalf("获取JetStream失败: %v", err)
+		t.Fatalf("获取JetStream失败: %v", err)
+	}
+	js := jsc.Legacy
+
+	// 3. 确保流存在
+	setupEventsStream(t, js)
+
+	// 4. 发布持久化消息
+	const published = 3
+	for i := 1; i <= published; i++ {
+		if _, err := js.Publish("events.pull", []byte(fmt.Sprintf(`{"msg":"消息 #%d"}`, i))); err != nil {
+			t.Fatalf("发布消息失败: %v", err)
+		}
 	}
 
 	// 5. 订阅并拉取持久化消息
 	sub, err := js.PullSubscribe("events.*", "my_consumer")
 
 	if err != nil {
-		log.Fatalf("订阅失败: %v", err)
+		t.Fatalf("订阅失败: %v", err)
 	}
 
-	fmt.Println("拉取消息:")
+	received := 0
 	for {
 		msgs, err := sub.Fetch(3, nats.MaxWait(2*time.Second))
 		if err != nil && err != nats.ErrTimeout {
-			log.Fatalf("拉取消息失败: %v", err)
+			t.Fatalf("拉取消息失败: %v", err)
 		}
 		if len(msgs) == 0 {
-			fmt.Println("没有更多消息，退出。")
+			log.Info("no more messages", LogKeyConsumer, "my_consumer")
 			break
 		}
 		for _, msg := range msgs {
-			fmt.Printf("收到消息:%s %s\n", msg.Subject, string(msg.Data))
+			md, _ := msg.Metadata()
+			log.Info("message received", LogKeySubject, msg.Subject, LogKeyConsumer, "my_consumer", LogKeySeq, md.Sequence.Stream, "data", string(msg.Data))
 			msg.Ack()
+			received++
 		}
 	}
+	if received < published {
+		t.Errorf("拉取的消息数量不足: got %d, want >= %d", received, published)
+	}
 }
@@ -13,21 +13,19 @@ func TestStreamQueueSub(t *testing.T) {
 		testSubject = "events.user.test"
 		testQueue   = "test-queue"
 		testDurable = "test-durable"
-		testDomain  = "hub"
 	)
 
 	// 1. 连接到 NATS 服务器
-	nc, err := NewNATSConnect()
-	if err != nil {
-		t.Fatalf("连接失败: %v", err)
-	}
+	nc := setupTestConnection(t)
 	defer nc.Close()
 
 	// 2. 获取 JetStream 上下文
-	js, err := nc.JetStream(nats.Domain(testDomain))
+	jsc, err := testJetStream(nc)
 	if err != nil {
 		t.Fatalf("获取JetStream失败: %v", err)
 	}
+	js := jsc.Legacy
+	setupEventsStream(t, js)
 
 	// 3. 创建一个 channel 来接收消息和错误
 	msgCh := make(chan *nats.Msg, 1)
@@ -0,0 +1,227 @@
+package nats_client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TLSFiles 管理 CA 与客户端证书文件。
+// 每次 (重) 连接时检查文件是否变化，变化后重新加载，
+// 新文件无法解析时继续使用上一次成功加载的内容。
+type TLSFiles struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// Logger 记录重新加载失败与轮换，为 nil 时使用 slog.Default()
+	Logger *slog.Logger
+	// ForceReconnect 为 true 时 Watch 发现轮换后强制重连，否则只预先加载，下一次重连时生效
+	ForceReconnect bool
+
+	mu        sync.Mutex
+	caStamp   fileStamp
+	certStamp fileStamp
+	pool      *x509.CertPool
+	cert      *tls.Certificate
+}
+
+// fileStamp 文件内容的 SHA-256，用于判断是否发生了轮换。
+// 不使用修改时间与大小：同一秒内写入等长的新证书时它们可能都不变
+type fileStamp [sha256.Size]byte
+
+// statFiles 返回 paths 的内容合在一起的摘要
+func statFiles(paths ...string) (fileStamp, error) {
+	h := sha256.New()
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fileStamp{}, err
+		}
+		// 前缀长度，避免内容在文件之间移动时摘要不变
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(data))))
+		h.Write(data)
+	}
+	return fileStamp(h.Sum(nil)), nil
+}
+
+// watchFiles 每隔 interval 调用 reload 预先加载并校验轮换的文件，连接不受影响，下一次重连时使用新文件。
+// force 为 true 时轮换后刷新缓冲区并强制重连，使新的凭证或证书立即生效。
+// kind 与 path 用于日志，连接关闭后退出
+func watchFiles(nc *nats.Conn, interval time.Duration, l *slog.Logger, kind, path string, force bool, reload func() (bool, error)) {
+	l = logger(l)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if nc.IsClosed() {
+			return
+		}
+		changed, err := reload()
+		if err != nil {
+			l.Warn("nats "+kind+" reload failed", LogKeyPath, path, errAttr(err))
+		}
+		if !changed {
+			continue
+		}
+		if !force {
+			l.Info("nats "+kind+" rotated, used on next reconnect", LogKeyPath, path, LogKeyConn, nc.Opts.Name)
+			continue
+		}
+		l.Info("nats "+kind+" rotated, reconnecting", LogKeyPath, path, LogKeyConn, nc.Opts.Name)
+		// 先把已经写入缓冲区的消息发出去，ForceReconnect 会直接关闭当前的套接字
+		if nc.IsConnected() {
+			nc.FlushTimeout(interval)
+		}
+		if err := nc.ForceReconnect(); err != nil {
+			l.Error("nats force reconnect failed", LogKeyConn, nc.Opts.Name, errAttr(err))
+		}
+	}
+}
+
+// NewTLSFiles 加载证书文件，caFile 与 certFile/keyFile 可以只设置其中一组
+func NewTLSFiles(caFile, certFile, keyFile string) (*TLSFiles, error) {
+	if caFile == "" && certFile == "" {
+		return nil, errors.New("nats: tls requires a ca or client certificate")
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("nats: cert and key must be set together")
+	}
+	f := &TLSFiles{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload 重新加载发生变化的文件，返回是否有文件被替换
+func (f *TLSFiles) Reload() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	changed := false
+	var errs []error
+	if f.CAFile != "" {
+		st, err := statFiles(f.CAFile)
+		if err == nil && st != f.caStamp {
+			var pool *x509.CertPool
+			if pool, err = loadCertPool(f.CAFile); err == nil {
+				f.pool, f.caStamp, changed = pool, st, true
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if f.CertFile != "" {
+		st, err := statFiles(f.CertFile, f.KeyFile)
+		if err == nil && st != f.certStamp {
+			var cert tls.Certificate
+			if cert, err = loadKeyPair(f.CertFile, f.KeyFile); err == nil {
+				f.cert, f.certStamp, changed = &cert, st, true
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return changed, errors.Join(errs...)
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("nats: read ca %s: %w", file, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("nats: no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+func loadKeyPair(certFile, keyFile string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("nats: load client certificate: %w", err)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("nats: parse client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// RootCAs 返回当前的 CA 证书池，供 nats.ClientTLSConfig 在每次连接时调用
+func (f *TLSFiles) RootCAs() (*x509.CertPool, error) {
+	f.Reload()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pool == nil {
+		return nil, fmt.Errorf("nats: ca %s not loaded", f.CAFile)
+	}
+	return f.pool, nil
+}
+
+// Certificate 返回当前的客户端证书，供 nats.ClientTLSConfig 在每次连接时调用
+func (f *TLSFiles) Certificate() (tls.Certificate, error) {
+	f.Reload()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cert == nil {
+		return tls.Certificate{}, fmt.Errorf("nats: certificate %s not loaded", f.CertFile)
+	}
+	return *f.cert, nil
+}
+
+// Option 返回使用这些文件的 nats.Option
+func (f *TLSFiles) Option() nats.Option {
+	var certCB nats.TLSCertHandler
+	var caCB nats.RootCAsHandler
+	if f.CertFile != "" {
+		certCB = f.Certificate
+	}
+	if f.CAFile != "" {
+		caCB = f.RootCAs
+	}
+	return nats.ClientTLSConfig(certCB, caCB)
+}
+
+// TLSConfig 返回使用当前证书的 tls.Config，供不经过 nats.go TLS 的传输 (例如 wss) 使用。
+// f 为 nil 时只使用系统 CA
+func (f *TLSFiles) TLSConfig(serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if f == nil {
+		return cfg, nil
+	}
+	if f.CAFile != "" {
+		pool, err := f.RootCAs()
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if f.CertFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := f.Certificate()
+			return &cert, err
+		}
+	}
+	return cfg, nil
+}
+
+// Watch 定期检查并预先加载轮换的证书文件，行为与 CredsFile.Watch 相同
+func (f *TLSFiles) Watch(nc *nats.Conn, interval time.Duration) {
+	path := f.CertFile
+	if path == "" {
+		path = f.CAFile
+	}
+	watchFiles(nc, interval, f.Logger, "tls", path, f.ForceReconnect, f.Reload)
+}
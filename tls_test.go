@@ -0,0 +1,224 @@
+package nats_client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// testCA 测试用的自签名 CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "nats-client test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发证书并写入 dir/name.pem 与 dir/name-key.pem，返回证书序列号
+func (ca *testCA) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) *big.Int {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := big.NewInt(time.Now().UnixNano())
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return serial
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runTLSServer 启动要求客户端证书的内嵌 nats-server
+func runTLSServer(t *testing.T, dir string) *server.Server {
+	t.Helper()
+	tc, err := server.GenTLSConfig(&server.TLSConfigOpts{
+		CertFile: filepath.Join(dir, "server.pem"),
+		KeyFile:  filepath.Join(dir, "server-key.pem"),
+		CaFile:   filepath.Join(dir, "ca.pem"),
+		Verify:   true,
+	})
+	if err != nil {
+		t.Fatalf("生成服务端 TLS 配置失败: %v", err)
+	}
+	return natstest.RunServer(t, natstest.WithoutJetStream(), func(o *server.Options) {
+		o.TLS = true
+		o.TLSVerify = true
+		o.TLSConfig = tc
+	})
+}
+
+// setupPKI 生成 CA、服务端证书与客户端证书
+func setupPKI(t *testing.T) (string, *testCA) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem)
+	ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	return dir, ca
+}
+
+// TestMutualTLS 测试双向 TLS 连接
+func TestMutualTLS(t *testing.T) {
+	dir, _ := setupPKI(t)
+	s := runTLSServer(t, dir)
+
+	nc, err := Connect(
+		WithURL(s.ClientURL()),
+		WithDialer(nil),
+		WithRootCAs(filepath.Join(dir, "ca.pem")),
+		WithClientCert(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")),
+		WithTLSServerName("localhost"),
+	)
+	if err != nil {
+		t.Fatalf("双向 TLS 连接失败: %v", err)
+	}
+	defer nc.Close()
+	if _, err := nc.TLSConnectionState(); err != nil {
+		t.Errorf("连接未使用 TLS: %v", err)
+	}
+
+	// 缺少客户端证书时应被服务器拒绝
+	_, err = Connect(
+		WithURL(s.ClientURL()),
+		WithDialer(nil),
+		WithRootCAs(filepath.Join(dir, "ca.pem")),
+		WithReconnect(0, 0),
+	)
+	if err == nil {
+		t.Error("缺少客户端证书时期望连接失败")
+	}
+}
+
+// TestTLSFilesReload 测试证书轮换后重新加载，以及损坏的新文件不会替换旧证书
+func TestTLSFilesReload(t *testing.T) {
+	dir, ca := setupPKI(t)
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+
+	files, err := NewTLSFiles(filepath.Join(dir, "ca.pem"), certFile, keyFile)
+	if err != nil {
+		t.Fatalf("加载证书失败: %v", err)
+	}
+	before, _ := files.Certificate()
+
+	if changed, err := files.Reload(); changed || err != nil {
+		t.Errorf("文件未变化时不应重新加载: %v %v", changed, err)
+	}
+
+	// 轮换证书，按内容判断，不依赖修改时间
+	serial := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	after, err := files.Certificate()
+	if err != nil {
+		t.Fatalf("读取新证书失败: %v", err)
+	}
+	if after.Leaf.SerialNumber.Cmp(serial) != 0 || after.Leaf.SerialNumber.Cmp(before.Leaf.SerialNumber) == 0 {
+		t.Errorf("证书未轮换: got %v, want %v", after.Leaf.SerialNumber, serial)
+	}
+
+	// 写入一半的文件不应替换当前证书
+	writeFile(t, certFile, []byte("-----BEGIN CERTIFICATE-----\n"))
+	if _, err := files.Reload(); err == nil {
+		t.Error("损坏的证书期望报错")
+	}
+	kept, err := files.Certificate()
+	if err != nil || kept.Leaf.SerialNumber.Cmp(serial) != 0 {
+		t.Errorf("损坏的证书不应替换当前证书: %v", err)
+	}
+}
+
+// TestTLSWatchReconnect 测试开启 ReconnectOnRotate 时证书轮换后自动重连并使用新证书
+func TestTLSWatchReconnect(t *testing.T) {
+	dir, ca := setupPKI(t)
+	s := runTLSServer(t, dir)
+
+	reconnected := make(chan struct{}, 1)
+	nc, err := Connect(
+		WithURL(s.ClientURL()),
+		WithDialer(nil),
+		WithRootCAs(filepath.Join(dir, "ca.pem")),
+		WithClientCert(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")),
+		WithTLSWatch(20*time.Millisecond),
+		WithReconnectOnRotate(),
+		WithEventHandler(func(e Event) {
+			if e.Type == EventReconnected {
+				select {
+				case reconnected <- struct{}{}:
+				default:
+				}
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("证书轮换后未重连")
+	}
+	if err := nc.Flush(); err != nil {
+		t.Errorf("重连后连接不可用: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package nats_client
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nats.go/micro"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName 创建 span 时使用的 instrumentation 名称
+const TracerName = "github.com/zjzhang-cn/nats-client"
+
+// HeaderCarrier 将 nats.Header 适配为 propagation.TextMapCarrier
+type HeaderCarrier nats.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Tracing 在 nats.Header 中传递 W3C traceparent/tracestate，
+// 并为发布、请求与消息处理创建 producer/client/consumer/server span。
+// 零值可用，使用全局 TracerProvider 与 W3C TraceContext 传播器
+type Tracing struct {
+	// TracerProvider 为 nil 时使用 otel.GetTracerProvider()
+	TracerProvider trace.TracerProvider
+	// Propagator 为 nil 时使用 propagation.TraceContext
+	Propagator propagation.TextMapPropagator
+}
+
+func (t *Tracing) tracer() trace.Tracer {
+	tp := otel.GetTracerProvider()
+	if t != nil && t.TracerProvider != nil {
+		tp = t.TracerProvider
+	}
+	return tp.Tracer(TracerName)
+}
+
+func (t *Tracing) propagator() propagation.TextMapPropagator {
+	if t != nil && t.Propagator != nil {
+		return t.Propagator
+	}
+	return propagation.TraceContext{}
+}
+
+// Inject 将 ctx 中的 trace 上下文写入消息头
+func (t *Tracing) Inject(ctx context.Context, msg *nats.Msg) {
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	t.propagator().Inject(ctx, HeaderCarrier(msg.Header))
+}
+
+// Extract 从消息头读取 trace 上下文
+func (t *Tracing) Extract(ctx context.Context, h nats.Header) context.Context {
+	if h == nil {
+		return ctx
+	}
+	return t.propagator().Extract(ctx, HeaderCarrier(h))
+}
+
+// msgAttributes 消息的通用属性
+func msgAttributes(op, subject string, size int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "nats"),
+		attribute.String("messaging.operation.type", op),
+		attribute.String("messaging.destination.name", subject),
+		attribute.Int("messaging.message.body.size", size),
+	}
+}
+
+// jsAttributes JetStream 消息的流、消费者与序号属性
+func jsAttributes(md *jetstream.MsgMetadata) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.nats.stream", md.Stream),
+		attribute.String("messaging.consumer.group.name", md.Consumer),
+		attribute.Int64("messaging.nats.stream.sequence", int64(md.Sequence.Stream)),
+		attribute.Int64("messaging.nats.consumer.sequence", int64(md.Sequence.Consumer)),
+		attribute.Int64("messaging.nats.num_delivered", int64(md.NumDelivered)),
+	}
+}
+
+// endSpan 记录错误后结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// start 创建 span 并将 trace 上下文写入消息头
+func (t *Tracing) start(ctx context.Context, op string, kind trace.SpanKind, msg *nats.Msg) (context.Context, trace.Span) {
+	ctx, span := t.tracer().Start(ctx, op+" "+msg.Subject,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(msgAttributes(op, msg.Subject, len(msg.Data))...),
+	)
+	t.Inject(ctx, msg)
+	return ctx, span
+}
+
+// Publish 在 producer span 中发布消息
+func (t *Tracing) Publish(ctx context.Context, nc *nats.Conn, msg *nats.Msg) error {
+	_, span := t.start(ctx, "publish", trace.SpanKindProducer, msg)
+	err := nc.PublishMsg(msg)
+	endSpan(span, err)
+	return err
+}
+
+// Request 在 client span 中发送请求并等待响应，ctx 没有截止时间时使用 timeout
+func (t *Tracing) Request(ctx context.Context, nc *nats.Conn, msg *nats.Msg, timeout time.Duration) (*nats.Msg, error) {
+	if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx, span := t.start(ctx, "request", trace.SpanKindClient, msg)
+	resp, err := nc.RequestMsgWithContext(ctx, msg)
+	if err == nil {
+		if code := resp.Header.Get(micro.ErrorCodeHeader); code != "" {
+			span.SetAttributes(attribute.String("rpc.response.status_code", code))
+			span.SetStatus(codes.Error, resp.Header.Get(micro.ErrorHeader))
+		}
+	}
+	endSpan(span, err)
+	return resp, err
+}
+
+// PublishJetStream 在 producer span 中同步发布到 JetStream，span 记录确认的流与序号
+func (t *Tracing) PublishJetStream(ctx context.Context, js jetstream.JetStream, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	ctx, span := t.start(ctx, "publish", trace.SpanKindProducer, msg)
+	ack, err := js.PublishMsg(ctx, msg, opts...)
+	if err == nil {
+		span.SetAttributes(
+			attribute.String("messaging.nats.stream", ack.Stream),
+			attribute.Int64("messaging.nats.stream.sequence", int64(ack.Sequence)),
+		)
+		if ack.Duplicate {
+			span.SetAttributes(attribute.Bool("messaging.nats.duplicate", true))
+		}
+	}
+	endSpan(span, err)
+	return ack, err
+}
+
+// MsgHandler 包装核心订阅的处理函数，在以消息头中 trace 上下文为父的 consumer span 中调用 h
+func (t *Tracing) MsgHandler(h func(context.Context, *nats.Msg)) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		ctx := t.Extract(context.Background(), msg.Header)
+		attrs := msgAttributes("process", msg.Subject, len(msg.Data))
+		if msg.Sub != nil && msg.Sub.Queue != "" {
+			attrs = append(attrs, attribute.String("messaging.consumer.group.name", msg.Sub.Queue))
+		}
+		ctx, span := t.tracer().Start(ctx, "process "+msg.Subject,
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+		h(ctx, msg)
+	}
+}
+
+// JetStreamHandler 包装 JetStream 消费者的处理函数，span 记录流、消费者与序号
+func (t *Tracing) JetStreamHandler(h func(context.Context, jetstream.Msg)) jetstream.MessageHandler {
+	return func(msg jetstream.Msg) {
+		ctx := t.Extract(context.Background(), msg.Headers())
+		attrs := msgAttributes("process", msg.Subject(), len(msg.Data()))
+		if md, err := msg.Metadata(); err == nil {
+			attrs = append(attrs, jsAttributes(md)...)
+		}
+		ctx, span := t.tracer().Start(ctx, "process "+msg.Subject(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+		h(ctx, msg)
+	}
+}
+
+// MicroHandler 包装微服务端点的处理函数，在 server span 中调用 h，
+// req.Error 的错误码与描述记录为 span 状态
+func (t *Tracing) MicroHandler(h func(context.Context, micro.Request)) micro.Handler {
+	return micro.HandlerFunc(func(req micro.Request) {
+		ctx := t.Extract(context.Background(), nats.Header(req.Headers()))
+		ctx, span := t.tracer().Start(ctx, "process "+req.Subject(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(msgAttributes("process", req.Subject(), len(req.Data()))...),
+		)
+		defer span.End()
+		h(ctx, &tracedRequest{Request: req, span: span})
+	})
+}
+
+// tracedRequest 记录响应结果的 micro.Request
+type tracedRequest struct {
+	micro.Request
+	span trace.Span
+}
+
+func (r *tracedRequest) Respond(data []byte, opts ...micro.RespondOpt) error {
+	err := r.Request.Respond(data, opts...)
+	r.record(err)
+	return err
+}
+
+func (r *tracedRequest) RespondJSON(v any, opts ...micro.RespondOpt) error {
+	err := r.Request.RespondJSON(v, opts...)
+	r.record(err)
+	return err
+}
+
+func (r *tracedRequest) Error(code, description string, data []byte, opts ...micro.RespondOpt) error {
+	r.span.SetAttributes(attribute.String("rpc.response.status_code", code))
+	r.span.SetStatus(codes.Error, description)
+	err := r.Request.Error(code, description, data, opts...)
+	r.record(err)
+	return err
+}
+
+func (r *tracedRequest) record(err error) {
+	if err != nil {
+		r.span.RecordError(err)
+		r.span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,181 @@
+package nats_client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nats.go/micro"
+	"github.com/zjzhang-cn/nats-client/natstest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracing(t *testing.T) (*Tracing, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return &Tracing{TracerProvider: tp}, exp
+}
+
+// waitSpan 等待名称为 name 的 span 结束
+func waitSpan(t *testing.T, exp *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, s := range exp.GetSpans() {
+			if s.Name == name {
+				return s
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("等待 span %q 超时", name)
+	return tracetest.SpanStub{}
+}
+
+func spanAttr(s tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range s.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// expectChild 检查 child 是 parent 的子 span
+func expectChild(t *testing.T, parent, child tracetest.SpanStub) {
+	t.Helper()
+	if child.Parent.TraceID() != parent.SpanContext.TraceID() || child.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Errorf("%s 的父 span 不是 %s", child.Name, parent.Name)
+	}
+}
+
+// TestTracingPublishSubscribe 测试核心发布与订阅之间传递 trace 上下文
+func TestTracingPublishSubscribe(t *testing.T) {
+	tr, exp := newTestTracing(t)
+	nc := natstest.Connect(t, natstest.RunServer(t))
+
+	got := make(chan trace.SpanContext, 1)
+	if _, err := nc.QueueSubscribe("trace.core", "workers", tr.MsgHandler(func(ctx context.Context, m *nats.Msg) {
+		got <- trace.SpanContextFromContext(ctx)
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, root := tr.tracer().Start(context.Background(), "http")
+	msg := nats.NewMsg("trace.core")
+	msg.Data = []byte("hello")
+	if err := tr.Publish(ctx, nc, msg); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+	if msg.Header.Get("traceparent") == "" {
+		t.Error("消息头中缺少 traceparent")
+	}
+
+	select {
+	case sc := <-got:
+		if sc.TraceID() != root.SpanContext().TraceID() {
+			t.Error("处理函数的 trace 与发布方不一致")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待消息超时")
+	}
+	pub := waitSpan(t, exp, "publish trace.core")
+	proc := waitSpan(t, exp, "process trace.core")
+	if pub.SpanKind != trace.SpanKindProducer || proc.SpanKind != trace.SpanKindConsumer {
+		t.Errorf("span 类型不匹配: %v %v", pub.SpanKind, proc.SpanKind)
+	}
+	expectChild(t, waitSpan(t, exp, "http"), pub)
+	expectChild(t, pub, proc)
+	if v := spanAttr(proc, "messaging.consumer.group.name").AsString(); v != "workers" {
+		t.Errorf("队列组属性不匹配: %q", v)
+	}
+	if v := spanAttr(proc, "messaging.message.body.size").AsInt64(); v != 5 {
+		t.Errorf("消息大小属性不匹配: %d", v)
+	}
+}
+
+// TestTracingJetStream 测试 JetStream 发布与消费的 span 记录流与序号
+func TestTracingJetStream(t *testing.T) {
+	tr, exp := newTestTracing(t)
+	nc := natstest.Connect(t, natstest.RunServer(t))
+	js, err := jetstream.NewWithDomain(nc, natstest.Domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "TRACE", Subjects: []string{"trace.js"}}); err != nil {
+		t.Fatal(err)
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, "TRACE", jetstream.ConsumerConfig{Durable: "tracer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tr.PublishJetStream(ctx, js, &nats.Msg{Subject: "trace.js", Data: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	cc, err := cons.Consume(tr.JetStreamHandler(func(ctx context.Context, m jetstream.Msg) {
+		m.Ack()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Stop()
+
+	pub := waitSpan(t, exp, "publish trace.js")
+	proc := waitSpan(t, exp, "process trace.js")
+	expectChild(t, pub, proc)
+	for _, s := range []tracetest.SpanStub{pub, proc} {
+		if v := spanAttr(s, "messaging.nats.stream").AsString(); v != "TRACE" {
+			t.Errorf("%s 流属性不匹配: %q", s.Name, v)
+		}
+		if v := spanAttr(s, "messaging.nats.stream.sequence").AsInt64(); v != 1 {
+			t.Errorf("%s 序号属性不匹配: %d", s.Name, v)
+		}
+	}
+	if v := spanAttr(proc, "messaging.consumer.group.name").AsString(); v != "tracer" {
+		t.Errorf("消费者属性不匹配: %q", v)
+	}
+}
+
+// TestTracingMicro 测试请求经过微服务端点时传递 trace 上下文，错误响应记录为 span 状态
+func TestTracingMicro(t *testing.T) {
+	tr, exp := newTestTracing(t)
+	nc := natstest.Connect(t, natstest.RunServer(t))
+	sv, err := micro.AddService(nc, micro.Config{Name: "UserSV", Version: "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Stop()
+	sv.AddEndpoint("check", tr.MicroHandler(func(ctx context.Context, req micro.Request) {
+		req.Respond(req.Data())
+	}), micro.WithEndpointSubject("User.check"))
+	sv.AddEndpoint("login", tr.MicroHandler(func(ctx context.Context, req micro.Request) {
+		req.Error("400", "Bad Request", nil)
+	}), micro.WithEndpointSubject("User.login"))
+
+	ctx := context.Background()
+	resp, err := tr.Request(ctx, nc, &nats.Msg{Subject: "User.check", Data: []byte("ok")}, time.Second)
+	if err != nil || string(resp.Data) != "ok" {
+		t.Fatalf("请求失败: %v", err)
+	}
+	expectChild(t, waitSpan(t, exp, "request User.check"), waitSpan(t, exp, "process User.check"))
+
+	if _, err := tr.Request(ctx, nc, &nats.Msg{Subject: "User.login"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"request User.login", "process User.login"} {
+		s := waitSpan(t, exp, name)
+		if s.Status.Code != codes.Error || spanAttr(s, "rpc.response.status_code").AsString() != "400" {
+			t.Errorf("%s 应记录错误状态: %+v", name, s.Status)
+		}
+	}
+}
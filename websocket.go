@@ -0,0 +1,407 @@
+package nats_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// nats.go 自带的 ws:// 支持无法设置握手请求头，wss 的握手又发生在 CustomDialer 之上，
+// 因此 WebSocket 模式下由 wsDialer 完成 TLS、握手与分帧，nats.go 只看到普通的字节流。
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket 帧的操作码
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// 帧长度上限，超过时断开连接，避免按对端声明的长度分配内存
+const (
+	wsMaxControlSize = 125     // RFC 6455 规定控制帧的负载不超过 125 字节
+	wsMaxInfoSize    = 1 << 20 // 服务器 INFO 帧，包含集群地址列表也远小于此
+)
+
+// isWebSocketURL 判断地址列表是否使用 ws:// 或 wss://
+func isWebSocketURL(urls string) bool {
+	for _, u := range strings.Split(urls, ",") {
+		u = strings.ToLower(strings.TrimSpace(u))
+		if strings.HasPrefix(u, "ws://") || strings.HasPrefix(u, "wss://") {
+			return true
+		}
+	}
+	return false
+}
+
+// wsEndpoint 一个 WebSocket 服务器地址
+type wsEndpoint struct {
+	tls  bool
+	host string // 握手时的 Host
+	path string
+}
+
+// wsDialer 建立 WebSocket 连接的拨号器，实现 nats.CustomDialer
+type wsDialer struct {
+	dialer    nats.CustomDialer // 底层 TCP 拨号器，nil 表示直连
+	header    http.Header
+	tlsConfig func() (*tls.Config, error)
+	timeout   time.Duration
+
+	// endpoints 以 host:port 为键，记录地址列表中每个服务器的协议与路径；
+	// 服务器通告的其他节点使用第一个地址的协议
+	endpoints map[string]wsEndpoint
+	fallback  wsEndpoint
+}
+
+// newWSDialer 解析 ws(s):// 地址列表，返回拨号器与交给 nats.go 的 nats:// 地址
+func newWSDialer(urls string) (*wsDialer, []string, error) {
+	d := &wsDialer{endpoints: make(map[string]wsEndpoint)}
+	var servers []string
+	for i, s := range strings.Split(urls, ",") {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return nil, nil, fmt.Errorf("nats: invalid websocket url %q: %w", s, err)
+		}
+		var ep wsEndpoint
+		port := u.Port()
+		switch strings.ToLower(u.Scheme) {
+		case "ws":
+			if port == "" {
+				port = "80"
+			}
+		case "wss":
+			ep.tls = true
+			if port == "" {
+				port = "443"
+			}
+		default:
+			return nil, nil, fmt.Errorf("nats: cannot mix %s:// with websocket urls", u.Scheme)
+		}
+		ep.host, ep.path = u.Host, u.EscapedPath()
+		addr := net.JoinHostPort(u.Hostname(), port)
+		d.endpoints[addr] = ep
+		if i == 0 {
+			d.fallback = wsEndpoint{tls: ep.tls}
+		}
+		srv := url.URL{Scheme: "nats", User: u.User, Host: addr}
+		servers = append(servers, srv.String())
+	}
+	return d, servers, nil
+}
+
+// Dial 实现 nats.CustomDialer
+func (d *wsDialer) Dial(network, address string) (net.Conn, error) {
+	ctx := context.Background()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	ep, ok := d.endpoints[address]
+	if !ok {
+		ep = d.fallback
+		ep.host = address
+	}
+
+	var conn net.Conn
+	var err error
+	switch cd := d.dialer.(type) {
+	case nil:
+		var nd net.Dialer
+		conn, err = nd.DialContext(ctx, network, address)
+	case interface {
+		DialContext(context.Context, string, string) (net.Conn, error)
+	}:
+		conn, err = cd.DialContext(ctx, network, address)
+	default:
+		conn, err = cd.Dial(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if ep.tls {
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if d.tlsConfig != nil {
+			if cfg, err = d.tlsConfig(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		if cfg.ServerName == "" {
+			host, _, _ := net.SplitHostPort(address)
+			cfg.ServerName = host
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("nats: websocket tls: %w", err)
+		}
+		conn = tc
+	}
+
+	wc, err := wsHandshake(conn, ep, d.header)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	return wc, nil
+}
+
+// wsHandshake 发送升级请求并校验响应
+func wsHandshake(conn net.Conn, ep wsEndpoint, header http.Header) (*wsConn, error) {
+	p := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, p); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(p)
+
+	path := ep.path
+	if path == "" {
+		path = "/"
+	}
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Opaque: path},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+		Host:       ep.host,
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("nats: websocket handshake: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("nats: websocket handshake: %s", resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, errors.New("nats: websocket handshake: invalid upgrade response")
+	}
+	return &wsConn{Conn: conn, br: br, tls: ep.tls}, nil
+}
+
+// wsConn 将 WebSocket 二进制帧转换为字节流
+type wsConn struct {
+	net.Conn
+	br  *bufio.Reader
+	tls bool
+
+	rmu       sync.Mutex
+	remaining int64  // 当前数据帧尚未读取的字节数
+	pending   []byte // 已读取但尚未交给调用方的数据
+	gotInfo   bool
+
+	wmu sync.Mutex
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for len(c.pending) == 0 && c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// nextFrame 读取下一个帧头，处理控制帧
+func (c *wsConn) nextFrame() error {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return err
+	}
+	op := h[0] & 0x0F
+	masked := h[1]&0x80 != 0
+	n := int64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return err
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if n < 0 {
+		return errors.New("nats: websocket: invalid frame length")
+	}
+	if masked {
+		return errors.New("nats: websocket: masked frame from server")
+	}
+
+	switch op {
+	case wsContinuation, wsText, wsBinary:
+		if c.gotInfo {
+			c.remaining = n
+			return nil
+		}
+		// 第一帧是服务器的 INFO。TLS 已在 WebSocket 层完成，
+		// 清除其中的 TLS 要求，避免 nats.go 再次握手
+		if n > wsMaxInfoSize {
+			return fmt.Errorf("nats: websocket: info frame of %d bytes exceeds %d", n, wsMaxInfoSize)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(c.br, data); err != nil {
+			return err
+		}
+		c.gotInfo = true
+		if c.tls {
+			var err error
+			if data, err = clearTLSRequired(data); err != nil {
+				return err
+			}
+		}
+		c.pending = data
+		return nil
+	}
+
+	if n > wsMaxControlSize {
+		return errors.New("nats: websocket: control frame too large")
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+	switch op {
+	case wsPing:
+		return c.writeFrame(wsPong, payload)
+	case wsClose:
+		c.writeFrame(wsClose, payload)
+		return io.EOF
+	}
+	return nil
+}
+
+// clearTLSRequired 把 INFO 行中的 tls_required、tls_available 改为 false，INFO 之后的数据原样保留
+func clearTLSRequired(data []byte) ([]byte, error) {
+	line, rest, _ := bytes.Cut(data, []byte("\r\n"))
+	body, ok := bytes.CutPrefix(line, []byte("INFO"))
+	if !ok {
+		return nil, fmt.Errorf("nats: websocket: expected INFO, got %.32q", line)
+	}
+	var info map[string]json.RawMessage
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("nats: websocket: invalid INFO: %w", err)
+	}
+	for _, k := range []string{"tls_required", "tls_available"} {
+		if _, ok := info[k]; ok {
+			info[k] = json.RawMessage("false")
+		}
+	}
+	body, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte("INFO "), body...)
+	out = append(out, "\r\n"...)
+	return append(out, rest...), nil
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame 写入一个带掩码的完整帧，客户端发送的帧必须使用掩码
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|op)
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i&3])
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// WithWebSocketHeader 设置 WebSocket 握手请求头，例如认证网关需要的 Authorization 或 Cookie。
+// 只在地址为 ws:// 或 wss:// 时使用
+func WithWebSocketHeader(key, value string) Option {
+	return func(c *Config) error {
+		if c.WebSocketHeaders == nil {
+			c.WebSocketHeaders = make(map[string]string)
+		}
+		c.WebSocketHeaders[key] = value
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package nats_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/zjzhang-cn/nats-client/natstest"
+)
+
+// TestWebSocketHeaders 测试握手请求头：服务器从 Cookie 中读取 Token 认证
+func TestWebSocketHeaders(t *testing.T) {
+	port := natstest.FreePort(t)
+	natstest.RunServer(t, natstest.WithWebSocket(port, nil), func(o *server.Options) {
+		o.Authorization = "s3cret"
+		o.Websocket.TokenCookie = "nats_token"
+	})
+	url := fmt.Sprintf("ws://127.0.0.1:%d", port)
+
+	if nc, err := Connect(WithURL(url), WithReconnect(0, 0)); err == nil {
+		nc.Close()
+		t.Fatal("缺少 Cookie 时期望认证失败")
+	}
+
+	nc, err := Connect(WithURL(url), WithWebSocketHeader("Cookie", "nats_token=s3cret"))
+	if err != nil {
+		t.Fatalf("WebSocket 连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	sub, err := nc.SubscribeSync("ws.echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过 64KB 的消息使用 8 字节长度的帧
+	payload := make([]byte, 200<<10)
+	rand.Read(payload)
+	if err := nc.Publish("ws.echo", payload); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+	if !bytes.Equal(msg.Data, payload) {
+		t.Error("经 WebSocket 收到的消息内容不一致")
+	}
+}
+
+// TestWebSocketTLS 测试 wss:// 与 JetStream
+func TestWebSocketTLS(t *testing.T) {
+	dir, _ := setupPKI(t)
+	tc, err := server.GenTLSConfig(&server.TLSConfigOpts{
+		CertFile: filepath.Join(dir, "server.pem"),
+		KeyFile:  filepath.Join(dir, "server-key.pem"),
+	})
+	if err != nil {
+		t.Fatalf("生成服务端 TLS 配置失败: %v", err)
+	}
+	port := natstest.FreePort(t)
+	natstest.RunServer(t, natstest.WithWebSocket(port, tc))
+
+	nc, err := Connect(
+		WithURL(fmt.Sprintf("wss://127.0.0.1:%d/nats", port)),
+		WithRootCAs(filepath.Join(dir, "ca.pem")),
+	)
+	if err != nil {
+		t.Fatalf("wss 连接失败: %v", err)
+	}
+	defer nc.Close()
+
+	ctx := context.Background()
+	js, err := NewJetStream(ctx, nc, JetStreamConfig{Domain: natstest.Domain})
+	if err != nil {
+		t.Fatalf("创建 JetStream 客户端失败: %v", err)
+	}
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "WS", Subjects: []string{"ws.>"}}); err != nil {
+		t.Fatalf("创建流失败: %v", err)
+	}
+	if _, err := js.Publish(ctx, "ws.stored", []byte("hello")); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+
+	// 没有 CA 时无法校验服务器证书
+	if _, err := Connect(WithURL(fmt.Sprintf("wss://127.0.0.1:%d", port)), WithReconnect(0, 0)); err == nil {
+		t.Error("未配置 CA 时期望证书校验失败")
+	}
+}
+
+// TestWebSocketProxy 测试 WebSocket 连接同样经过代理拨号器，断开后可以重连
+func TestWebSocketProxy(t *testing.T) {
+	port := natstest.FreePort(t)
+	s := natstest.RunServer(t, natstest.WithWebSocket(port, nil))
+	p := runConnectProxy(t, fmt.Sprintf("127.0.0.1:%d", port), "", "")
+
+	events := make(chan Event, 64)
+	nc, err := Connect(
+		WithURL("ws://nats.internal:8080"),
+		WithProxy("http://"+p.ln.Addr().String(), ""),
+		WithReconnect(-1, 20*time.Millisecond),
+		WithEventChannel(events),
+	)
+	if err != nil {
+		t.Fatalf("经由代理的 WebSocket 连接失败: %v", err)
+	}
+	defer nc.Close()
+	if got := p.lastTarget(); got != "nats.internal:8080" {
+		t.Errorf("代理收到的目标不匹配: %q", got)
+	}
+
+	p.dropAll()
+	waitEvent(t, events, EventReconnected)
+	if _, err := nc.Request("ws.none", nil, 500*time.Millisecond); err != nats.ErrNoResponders {
+		t.Errorf("重连后请求结果不匹配: %v", err)
+	}
+	if s.NumClients() != 1 {
+		t.Errorf("客户端数量不匹配: %d", s.NumClients())
+	}
+}
+
+// TestWebSocketINFO 测试 INFO 改写：按 JSON 修改 TLS 字段，不依赖字段的书写格式，INFO 之后的数据原样保留
+func TestWebSocketINFO(t *testing.T) {
+	out, err := clearTLSRequired([]byte("INFO {\"server_id\":\"x\", \"tls_required\": true,\"tls_available\" :true}\r\nPING\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, rest, _ := bytes.Cut(out, []byte("\r\n"))
+	var info map[string]any
+	if err := json.Unmarshal(bytes.TrimPrefix(line, []byte("INFO ")), &info); err != nil {
+		t.Fatalf("改写后的 INFO %q: %v", line, err)
+	}
+	if info["tls_required"] != false || info["tls_available"] != false || info["server_id"] != "x" {
+		t.Errorf("INFO = %v", info)
+	}
+	if string(rest) != "PING\r\n" {
+		t.Errorf("INFO 之后的数据 = %q", rest)
+	}
+	if _, err := clearTLSRequired([]byte("PING\r\n")); err == nil {
+		t.Error("第一帧不是 INFO 时期望失败")
+	}
+}
+
+// TestWebSocketFrameLimit 测试对端声明的帧长度超过上限时直接断开，不按声明的长度分配内存
+func TestWebSocketFrameLimit(t *testing.T) {
+	frame := func(op byte, n uint64) []byte {
+		return binary.BigEndian.AppendUint64([]byte{0x80 | op, 127}, n)
+	}
+	tests := []struct {
+		name    string
+		gotInfo bool
+		frame   []byte
+	}{
+		{"info", false, frame(wsText, wsMaxInfoSize+1)},
+		{"control", true, frame(wsPing, wsMaxControlSize+1)},
+		{"negative", true, frame(wsBinary, 1<<63)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &wsConn{br: bufio.NewReader(bytes.NewReader(tt.frame)), gotInfo: tt.gotInfo}
+			if _, err := c.Read(make([]byte, 16)); err == nil || err == io.EOF {
+				t.Fatalf("Read() = %v, 期望帧长度错误", err)
+			}
+		})
+	}
+}